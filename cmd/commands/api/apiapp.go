@@ -540,6 +540,7 @@ func init() {
 	CmdApiapp.Flag.Var(&generate.Tables, "tables", "List of table names separated by a comma.")
 	CmdApiapp.Flag.Var(&generate.SQLDriver, "driver", "Database driver. Either mysql, postgres or sqlite.")
 	CmdApiapp.Flag.Var(&generate.SQLConn, "conn", "Connection string used by the driver to connect to a database instance.")
+	CmdApiapp.Flag.Var(&generate.SQLConnName, "db", "Name of a connection of database.connections in IZIfile.")
 	commands.AvailableCommands = append(commands.AvailableCommands, CmdApiapp)
 }
 
@@ -561,6 +562,7 @@ func createAPI(cmd *commands.Command, args []string) int {
 	if err != nil {
 		iziLogger.Log.Fatalf("%s", err)
 	}
+	utils.ResolveDatabase(generate.SQLConnName.String(), &generate.SQLDriver, &generate.SQLConn, false)
 	if generate.SQLDriver == "" {
 		generate.SQLDriver = "mysql"
 	}
@@ -582,11 +584,13 @@ func createAPI(cmd *commands.Command, args []string) int {
 	if generate.SQLConn != "" {
 		fmt.Fprintf(output, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", path.Join(appPath, "main.go"), "\x1b[0m")
 		mainGoContent := strings.Replace(apiMainconngo, "{{.Appname}}", packPath, -1)
-		mainGoContent = strings.Replace(mainGoContent, "{{.DriverName}}", string(generate.SQLDriver), -1)
+		mainGoContent = strings.Replace(mainGoContent, "{{.DriverName}}", generate.SQLDriverName(string(generate.SQLDriver)), -1)
 		if generate.SQLDriver == "mysql" {
 			mainGoContent = strings.Replace(mainGoContent, "{{.DriverPkg}}", `_ "github.com/go-sql-driver/mysql"`, -1)
 		} else if generate.SQLDriver == "postgres" {
 			mainGoContent = strings.Replace(mainGoContent, "{{.DriverPkg}}", `_ "github.com/lib/pq"`, -1)
+		} else if generate.SQLDriver == "sqlite" {
+			mainGoContent = strings.Replace(mainGoContent, "{{.DriverPkg}}", `_ "github.com/mattn/go-sqlite3"`, -1)
 		}
 		utils.WriteToFile(path.Join(appPath, "main.go"),
 			strings.Replace(
@@ -597,7 +601,7 @@ func createAPI(cmd *commands.Command, args []string) int {
 			),
 		)
 		iziLogger.Log.Infof("Using '%s' as 'driver'", generate.SQLDriver)
-		iziLogger.Log.Infof("Using '%s' as 'conn'", generate.SQLConn)
+		iziLogger.Log.Infof("Using '%s' as 'conn'", utils.MaskDSN(generate.SQLConn.String()))
 		iziLogger.Log.Infof("Using '%s' as 'tables'", generate.Tables)
 		generate.GenerateAppcode(string(generate.SQLDriver), string(generate.SQLConn), "3", string(generate.Tables), appPath)
 	} else {
@@ -0,0 +1,201 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package db loads fixtures into the database and exports its content.
+package db
+
+import (
+	"database/sql"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/izi-global/izi/cmd/commands"
+	"github.com/izi-global/izi/cmd/commands/version"
+	"github.com/izi-global/izi/generate"
+	iziLogger "github.com/izi-global/izi/logger"
+	"github.com/izi-global/izi/utils"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var CmdDb = &commands.Command{
+	UsageLine: "db [seed|export]",
+	Short:     "Loads fixtures into the database and exports its content",
+	Long: `The command 'db' fills the database with test data and exports its content as fixtures.
+
+  ▶ {{"To load the fixtures of database/seeds, or of the set database/seeds/[name]:"|bold}}
+
+    $ izi db seed [name] [-mode=insert|truncate|upsert] [-driver=mysql] [-conn="root:@tcp(127.0.0.1:3306)/test"]
+
+  ▶ {{"To export the content of tables as fixtures:"|bold}}
+
+    $ izi db export [name] [-tables="users,posts"] [-format=yml|json|csv]
+
+  A fixture file holds the rows of the table it is named after, e.g. users.yml. YAML
+  and JSON files contain a list of objects mapping the columns to their values, CSV
+  files a header row naming the columns, \N standing for NULL. The values of binary
+  columns are written as base64 after a "base64:" prefix. Tables are loaded in the
+  order of their foreign keys, in a single transaction.
+
+  With -mode=truncate the tables are emptied first, with -mode=upsert the rows whose
+  primary key exists are updated instead of inserted.
+`,
+	PreRun: func(cmd *commands.Command, args []string) { version.ShowShortVersionBanner() },
+	Run:    RunDb,
+}
+
+// Directory of the fixtures, relative to the application
+const seedsDir = "database/seeds"
+
+// Tables of izi itself, neither seeded nor exported
+var bookkeepingTables = map[string]bool{"migrations": true, "migrations_lock": true}
+
+var (
+	dbDriver utils.DocValue
+	dbConn   utils.DocValue
+	dbName   utils.DocValue
+	dbTables string
+	dbFormat string
+	dbMode   string
+)
+
+func init() {
+	CmdDb.Flag.Var(&dbDriver, "driver", "Database driver. Either mysql, postgres or sqlite.")
+	CmdDb.Flag.Var(&dbConn, "conn", "Connection string used by the driver to connect to a database instance.")
+	CmdDb.Flag.Var(&dbName, "db", "Name of a connection of database.connections in IZIfile.")
+	CmdDb.Flag.StringVar(&dbTables, "tables", "", "List of table names separated by a comma. Defaults to every table.")
+	CmdDb.Flag.StringVar(&dbFormat, "format", "yml", "Format of the exported fixtures. Either yml, json or csv.")
+	CmdDb.Flag.StringVar(&dbMode, "mode", "insert", "How seeded rows are written. Either insert, truncate or upsert.")
+	commands.AvailableCommands = append(commands.AvailableCommands, CmdDb)
+}
+
+// RunDb is the entry point of the db command
+func RunDb(cmd *commands.Command, args []string) int {
+	currpath, _ := os.Getwd()
+
+	if len(args) == 0 {
+		iziLogger.Log.Fatal("Command is missing. Run: izi help db")
+	}
+	// The name of the fixture set comes before the flags
+	subcommand, name := args[0], ""
+	args = args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	cmd.Flag.Parse(args)
+
+	utils.ResolveDatabase(dbName.String(), &dbDriver, &dbConn, true)
+	if dbDriver == "" {
+		dbDriver = "mysql"
+	}
+	if dbConn == "" && dbDriver == "sqlite" {
+		iziLogger.Log.Fatal("The sqlite driver needs the path of the database file, set it with -conn")
+	} else if dbConn == "" {
+		dbConn = "root:@tcp(127.0.0.1:3306)/test"
+	}
+	trans, ok := generate.GetDbTransformer(string(dbDriver))
+	if !ok {
+		iziLogger.Log.Fatalf("Unknown database driver '%s'. Driver must be one of mysql, postgres or sqlite", dbDriver)
+	}
+	iziLogger.Log.Infof("Using '%s' as 'driver'", dbDriver)
+	iziLogger.Log.Infof("Using '%s' as 'conn'", utils.MaskDSN(string(dbConn)))
+
+	db, err := sql.Open(generate.SQLDriverName(string(dbDriver)), string(dbConn))
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not connect to database using '%s': %s", utils.MaskDSN(string(dbConn)), err)
+	}
+	defer db.Close()
+
+	dir := path.Join(currpath, seedsDir, name)
+	d := &database{db: db, driver: string(dbDriver), trans: trans}
+	switch subcommand {
+	case "seed":
+		Seed(d, dir, dbMode)
+		iziLogger.Log.Success("Database seeded successfully!")
+	case "export":
+		Export(d, dir, splitTables(dbTables), dbFormat)
+		iziLogger.Log.Success("Tables exported successfully!")
+	default:
+		iziLogger.Log.Fatalf("Unknown command '%s'. Run: izi help db", subcommand)
+	}
+	return 0
+}
+
+// database is a connection with the introspection of its driver.
+type database struct {
+	db     *sql.DB
+	driver string
+	trans  generate.DbTransformer
+}
+
+// table reads the primary key and the foreign keys of the table.
+func (d *database) table(name string) *generate.Table {
+	table := &generate.Table{Name: name, Fk: make(map[string]*generate.ForeignKey)}
+	d.trans.GetConstraints(d.db, table, make(map[string]bool))
+	return table
+}
+
+// tableNames returns every table of the database.
+func (d *database) tableNames() []string {
+	var names []string
+	for _, name := range d.trans.GetTableNames(d.db) {
+		if !bookkeepingTables[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// sortTables orders the tables so every table comes after the ones its foreign keys reference.
+func (d *database) sortTables(names []string) []string {
+	deps := make(map[string][]string)
+	for _, name := range names {
+		for _, fk := range d.table(name).Fk {
+			if fk.RefTable != name {
+				deps[name] = append(deps[name], fk.RefTable)
+			}
+		}
+	}
+	return utils.SortByDependencies(names, deps)
+}
+
+// quote quotes an identifier for the driver.
+func (d *database) quote(name string) string {
+	if d.driver == "mysql" {
+		return "`" + strings.Replace(name, "`", "``", -1) + "`"
+	}
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// placeholder returns the n-th query parameter, from 1.
+func (d *database) placeholder(n int) string {
+	if d.driver == "postgres" {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+func splitTables(list string) []string {
+	var tables []string
+	for _, t := range strings.Split(list, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tables = append(tables, t)
+		}
+	}
+	return tables
+}
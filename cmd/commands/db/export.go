@@ -0,0 +1,229 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package db
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	iziLogger "github.com/izi-global/izi/logger"
+	"gopkg.in/yaml.v2"
+)
+
+// Layout of the exported dates, understood by every driver
+const exportTimeFormat = "2006-01-02 15:04:05"
+
+// Prefix of the binary values of fixtures, encoded as base64
+const binaryPrefix = "base64:"
+
+// Export writes the rows of the tables, every table when empty, to fixture
+// files of dir in the given format, ordered by primary key.
+func Export(d *database, dir string, tables []string, format string) {
+	if format == "yaml" {
+		format = "yml"
+	}
+	if format != "yml" && format != "json" && format != "csv" {
+		iziLogger.Log.Fatalf("Unknown format '%s'. Format must be one of yml, json or csv", format)
+	}
+	if len(tables) == 0 {
+		tables = d.tableNames()
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		iziLogger.Log.Fatalf("Could not create directory: %s", err)
+	}
+
+	for _, table := range tables {
+		columns, rows, err := d.exportRows(table)
+		if err != nil {
+			iziLogger.Log.Fatalf("Could not read table '%s': %s", table, err)
+		}
+		var content []byte
+		switch format {
+		case "json":
+			content, err = encodeJSONFixture(columns, rows)
+		case "csv":
+			content, err = encodeCSVFixture(columns, rows)
+		default:
+			content, err = encodeYAMLFixture(columns, rows)
+		}
+		if err != nil {
+			iziLogger.Log.Fatalf("Could not encode table '%s': %s", table, err)
+		}
+		file := path.Join(dir, table+"."+format)
+		if err := ioutil.WriteFile(file, content, 0644); err != nil {
+			iziLogger.Log.Fatalf("Could not write fixture: %s", err)
+		}
+		iziLogger.Log.Infof("|> Exported %d rows of '%s' to '%s'", len(rows), table, file)
+	}
+}
+
+// exportRows returns the columns and the rows of the table.
+func (d *database) exportRows(table string) ([]string, [][]interface{}, error) {
+	query := "SELECT * FROM " + d.quote(table)
+	if pk := d.table(table).Pk; pk != "" {
+		query += " ORDER BY " + d.quote(pk)
+	}
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var values [][]interface{}
+	for rows.Next() {
+		row := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, nil, err
+		}
+		for i := range row {
+			if row[i], err = exportValue(row[i], types[i].DatabaseTypeName()); err != nil {
+				return nil, nil, fmt.Errorf("column %s: %s", columns[i], err)
+			}
+		}
+		values = append(values, row)
+	}
+	return columns, values, rows.Err()
+}
+
+// exportValue converts a scanned value to a value of the fixture. MySQL
+// returns every value as text, the numbers are parsed back using the type
+// of their column. The values of binary columns are encoded as base64, the
+// dates of the columns with a time zone as RFC 3339.
+func exportValue(value interface{}, typeName string) (interface{}, error) {
+	typeName = strings.ToUpper(typeName)
+	switch v := value.(type) {
+	case time.Time:
+		if strings.HasSuffix(typeName, "TZ") {
+			return v.Format(time.RFC3339Nano), nil
+		}
+		return v.Format(exportTimeFormat), nil
+	case []byte:
+		if isBinaryType(typeName) {
+			return binaryPrefix + base64.StdEncoding.EncodeToString(v), nil
+		}
+		if !utf8.Valid(v) {
+			return nil, fmt.Errorf("binary value of a %s column, only binary columns are encoded", typeName)
+		}
+		s := string(v)
+		switch {
+		case strings.Contains(typeName, "INT"):
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return n, nil
+			}
+		case strings.Contains(typeName, "FLOAT") || strings.Contains(typeName, "DOUBLE") || typeName == "REAL":
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return f, nil
+			}
+		}
+		return s, nil
+	}
+	return value, nil
+}
+
+// isBinaryType reports whether the values of the column type are bytes.
+func isBinaryType(typeName string) bool {
+	typeName = strings.ToUpper(typeName)
+	return strings.Contains(typeName, "BLOB") || strings.Contains(typeName, "BINARY") || typeName == "BYTEA"
+}
+
+func encodeYAMLFixture(columns []string, rows [][]interface{}) ([]byte, error) {
+	items := make([]yaml.MapSlice, len(rows))
+	for i, row := range rows {
+		for j, column := range columns {
+			items[i] = append(items[i], yaml.MapItem{Key: column, Value: row[j]})
+		}
+	}
+	if len(items) == 0 {
+		return []byte("[]\n"), nil
+	}
+	return yaml.Marshal(items)
+}
+
+// encodeJSONFixture writes the objects by hand to keep the columns in order.
+func encodeJSONFixture(columns []string, rows [][]interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("[")
+	for i, row := range rows {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n  {")
+		for j, column := range columns {
+			key, err := json.Marshal(column)
+			if err != nil {
+				return nil, err
+			}
+			value, err := json.Marshal(row[j])
+			if err != nil {
+				return nil, err
+			}
+			if j > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, "\n    %s: %s", key, value)
+		}
+		b.WriteString("\n  }")
+	}
+	if len(rows) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("]\n")
+	return b.Bytes(), nil
+}
+
+func encodeCSVFixture(columns []string, rows [][]interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.Write(columns); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			if value == nil {
+				record[i] = csvNull
+			} else {
+				record[i] = fmt.Sprint(value)
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
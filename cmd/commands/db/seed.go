@@ -0,0 +1,343 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	iziLogger "github.com/izi-global/izi/logger"
+	"github.com/izi-global/izi/utils"
+	"gopkg.in/yaml.v2"
+)
+
+// Value of the NULL fields of CSV fixtures
+const csvNull = `\N`
+
+// fixtureExts are the extensions of the fixture files, by format.
+var fixtureExts = map[string]string{".yml": "yml", ".yaml": "yml", ".json": "json", ".csv": "csv"}
+
+// row is a row of a fixture, its columns in the order of the file.
+type row struct {
+	columns []string
+	values  []interface{}
+}
+
+// Seed loads the fixtures of dir into the database in a single transaction.
+// With the truncate mode the seeded tables are emptied first, with the upsert
+// mode the rows whose key exists are updated.
+func Seed(d *database, dir, mode string) {
+	if mode != "insert" && mode != "truncate" && mode != "upsert" {
+		iziLogger.Log.Fatalf("Unknown mode '%s'. Mode must be one of insert, truncate or upsert", mode)
+	}
+	if !utils.IsExist(dir) {
+		iziLogger.Log.Fatalf("Fixtures directory '%s' does not exist", dir)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not read fixtures directory: %s", err)
+	}
+	fixtures := make(map[string]string)
+	var tables []string
+	for _, f := range files {
+		ext := path.Ext(f.Name())
+		if f.IsDir() || fixtureExts[ext] == "" {
+			continue
+		}
+		table := strings.TrimSuffix(f.Name(), ext)
+		if _, ok := fixtures[table]; ok {
+			iziLogger.Log.Fatalf("Table '%s' has several fixture files", table)
+		}
+		fixtures[table] = f.Name()
+		tables = append(tables, table)
+	}
+	if only := splitTables(dbTables); len(only) > 0 {
+		for _, table := range only {
+			if _, ok := fixtures[table]; !ok {
+				iziLogger.Log.Fatalf("No fixture file for table '%s' in '%s'", table, dir)
+			}
+		}
+		tables = only
+	}
+	if len(tables) == 0 {
+		iziLogger.Log.Warnf("No fixture files in '%s'", dir)
+		return
+	}
+	tables = d.sortTables(tables)
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not start transaction: %s", err)
+	}
+	fail := func(format string, args ...interface{}) {
+		tx.Rollback()
+		iziLogger.Log.Fatalf(format, args...)
+	}
+
+	if mode == "truncate" {
+		// Referencing tables are emptied first
+		for i := len(tables) - 1; i >= 0; i-- {
+			if _, err := tx.Exec("DELETE FROM " + d.quote(tables[i])); err != nil {
+				fail("Could not empty table '%s': %s", tables[i], err)
+			}
+		}
+	}
+	for _, table := range tables {
+		rows, err := readFixture(path.Join(dir, fixtures[table]))
+		if err != nil {
+			fail("Could not read fixture '%s': %s", fixtures[table], err)
+		}
+		binary, err := d.binaryColumns(table)
+		if err != nil {
+			fail("Could not read the columns of table '%s': %s", table, err)
+		}
+		if err := decodeBinaryValues(rows, binary); err != nil {
+			fail("Could not read fixture '%s': %s", fixtures[table], err)
+		}
+		pk := ""
+		if mode == "upsert" && d.driver != "mysql" {
+			if pk = d.table(table).Pk; pk == "" {
+				fail("Table '%s' needs a single column primary key to be upserted", table)
+			}
+		}
+		for i, r := range rows {
+			if _, err := tx.Exec(d.insertStatement(table, r.columns, mode == "upsert", pk), r.values...); err != nil {
+				fail("Could not insert row %d of '%s': %s", i+1, fixtures[table], err)
+			}
+		}
+		if d.driver == "postgres" {
+			if err := resetSequence(tx, d, table); err != nil {
+				fail("Could not reset the sequence of table '%s': %s", table, err)
+			}
+		}
+		iziLogger.Log.Infof("|> Seeded %d rows into '%s'", len(rows), table)
+	}
+	if err := tx.Commit(); err != nil {
+		iziLogger.Log.Fatalf("Could not commit transaction: %s", err)
+	}
+}
+
+// insertStatement returns the statement inserting a row. Upserts update the
+// columns of the row whose key exists; pk names the conflicting key of
+// Postgres and SQLite.
+func (d *database) insertStatement(table string, columns []string, upsert bool, pk string) string {
+	quoted := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = d.quote(c)
+		placeholders[i] = d.placeholder(i + 1)
+	}
+	statement := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", d.quote(table), strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+	if !upsert {
+		return statement
+	}
+
+	var updates []string
+	for i, c := range columns {
+		if c == pk {
+			continue
+		}
+		if d.driver == "mysql" {
+			updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", quoted[i], quoted[i]))
+		} else {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", quoted[i], quoted[i]))
+		}
+	}
+	switch {
+	case d.driver == "mysql" && len(updates) == 0:
+		return strings.Replace(statement, "INSERT", "INSERT IGNORE", 1)
+	case d.driver == "mysql":
+		return statement + " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+	case len(updates) == 0:
+		return statement + " ON CONFLICT (" + d.quote(pk) + ") DO NOTHING"
+	}
+	return statement + " ON CONFLICT (" + d.quote(pk) + ") DO UPDATE SET " + strings.Join(updates, ", ")
+}
+
+// resetSequence moves the serial sequence of the primary key past the seeded
+// rows, so the rows inserted next do not collide with them.
+func resetSequence(tx *sql.Tx, d *database, table string) error {
+	pk := d.table(table).Pk
+	if pk == "" {
+		return nil
+	}
+	_, err := tx.Exec(fmt.Sprintf(`SELECT setval(s, (SELECT COALESCE(MAX(%s), 0) + 1 FROM %s), false)
+		FROM pg_get_serial_sequence($1, $2) s WHERE s IS NOT NULL`, d.quote(pk), d.quote(table)), d.quote(table), pk)
+	return err
+}
+
+// readFixture reads the rows of a YAML, JSON or CSV fixture file.
+func readFixture(file string) ([]row, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	switch fixtureExts[path.Ext(file)] {
+	case "json":
+		return readJSONFixture(content)
+	case "csv":
+		return readCSVFixture(content)
+	}
+	return readYAMLFixture(content)
+}
+
+func readYAMLFixture(content []byte) ([]row, error) {
+	var items []yaml.MapSlice
+	if err := yaml.Unmarshal(content, &items); err != nil {
+		return nil, err
+	}
+	rows := make([]row, len(items))
+	for i, item := range items {
+		for _, field := range item {
+			value, err := fixtureValue(field.Value)
+			if err != nil {
+				return nil, err
+			}
+			rows[i].columns = append(rows[i].columns, fmt.Sprint(field.Key))
+			rows[i].values = append(rows[i].values, value)
+		}
+	}
+	return rows, nil
+}
+
+func readJSONFixture(content []byte) ([]row, error) {
+	var items []map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&items); err != nil {
+		return nil, err
+	}
+	rows := make([]row, len(items))
+	for i, item := range items {
+		// JSON objects are unordered
+		for column := range item {
+			rows[i].columns = append(rows[i].columns, column)
+		}
+		sort.Strings(rows[i].columns)
+		for _, column := range rows[i].columns {
+			value, err := fixtureValue(item[column])
+			if err != nil {
+				return nil, err
+			}
+			rows[i].values = append(rows[i].values, value)
+		}
+	}
+	return rows, nil
+}
+
+func readCSVFixture(content []byte) ([]row, error) {
+	records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	rows := make([]row, len(records)-1)
+	for i, record := range records[1:] {
+		rows[i].columns = records[0]
+		for _, field := range record {
+			if field == csvNull {
+				rows[i].values = append(rows[i].values, nil)
+			} else {
+				rows[i].values = append(rows[i].values, field)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// binaryColumns returns the columns of the table holding bytes.
+func (d *database) binaryColumns(table string) (map[string]bool, error) {
+	rows, err := d.db.Query("SELECT * FROM " + d.quote(table) + " WHERE 1 = 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	binary := make(map[string]bool)
+	for _, t := range types {
+		binary[t.Name()] = isBinaryType(t.DatabaseTypeName())
+	}
+	return binary, nil
+}
+
+// decodeBinaryValues decodes the base64 values of the binary columns.
+func decodeBinaryValues(rows []row, binary map[string]bool) error {
+	for _, r := range rows {
+		for i, column := range r.columns {
+			s, ok := r.values[i].(string)
+			if !ok || !binary[column] || !strings.HasPrefix(s, binaryPrefix) {
+				continue
+			}
+			value, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, binaryPrefix))
+			if err != nil {
+				return fmt.Errorf("column %s: %s", column, err)
+			}
+			r.values[i] = value
+		}
+	}
+	return nil
+}
+
+// fixtureValue converts a decoded value to a query argument. Lists and
+// objects are stored as JSON.
+func fixtureValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.String(), nil
+	case []interface{}, map[string]interface{}, map[interface{}]interface{}, yaml.MapSlice:
+		encoded, err := json.Marshal(jsonValue(v))
+		return string(encoded), err
+	}
+	return value, nil
+}
+
+// jsonValue converts the maps decoded from YAML, keyed by interface{}, so
+// they can be encoded as JSON.
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = jsonValue(item)
+		}
+		return m
+	case yaml.MapSlice:
+		m := make(map[string]interface{}, len(v))
+		for _, item := range v {
+			m[fmt.Sprint(item.Key)] = jsonValue(item.Value)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = jsonValue(item)
+		}
+		return list
+	}
+	return value
+}
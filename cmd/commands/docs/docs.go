@@ -0,0 +1,70 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+package docs
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/izi-global/izi/cmd/commands"
+	"github.com/izi-global/izi/cmd/commands/version"
+	"github.com/izi-global/izi/generate/swaggerui"
+	iziLogger "github.com/izi-global/izi/logger"
+	"github.com/izi-global/izi/utils"
+)
+
+var CmdDocs = &commands.Command{
+	UsageLine: "docs ui install [-dir=swagger]",
+	Short:     "Manages the API documentation UI",
+	Long: `
+  ▶ {{"To install the Swagger UI bundled in izi, without network access:"|bold}}
+
+     $ izi docs ui install [-dir=swagger]
+
+  The files are extracted to the "swagger" folder of the current application, next to
+  the swagger.json written by "izi generate docs".
+`,
+	PreRun: func(cmd *commands.Command, args []string) { version.ShowShortVersionBanner() },
+	Run:    runDocs,
+}
+
+var dir utils.DocValue
+
+func init() {
+	CmdDocs.Flag.Var(&dir, "dir", "Directory where the Swagger UI is installed.")
+	commands.AvailableCommands = append(commands.AvailableCommands, CmdDocs)
+}
+
+func runDocs(cmd *commands.Command, args []string) int {
+	if len(args) < 2 || args[0] != "ui" || args[1] != "install" {
+		iziLogger.Log.Fatal("Wrong number of arguments. Run: izi help docs")
+	}
+	cmd.Flag.Parse(args[2:])
+
+	target := dir.String()
+	if target == "" {
+		target = "swagger"
+	}
+	if !filepath.IsAbs(target) {
+		currpath, _ := os.Getwd()
+		target = filepath.Join(currpath, target)
+	}
+
+	iziLogger.Log.Infof("Installing Swagger UI %s to '%s'...", swaggerui.Version, target)
+	if err := swaggerui.Install(target); err != nil {
+		iziLogger.Log.Fatalf("Could not install the Swagger UI: %s", err)
+	}
+	iziLogger.Log.Success("Swagger UI successfully installed!")
+	return 0
+}
@@ -46,6 +46,7 @@ func init() {
 	CmdHproseapp.Flag.Var(&generate.Tables, "tables", "List of table names separated by a comma.")
 	CmdHproseapp.Flag.Var(&generate.SQLDriver, "driver", "Database driver. Either mysql, postgres or sqlite.")
 	CmdHproseapp.Flag.Var(&generate.SQLConn, "conn", "Connection string used by the driver to connect to a database instance.")
+	CmdHproseapp.Flag.Var(&generate.SQLConnName, "db", "Name of a connection of database.connections in IZIfile.")
 	commands.AvailableCommands = append(commands.AvailableCommands, CmdHproseapp)
 }
 
@@ -64,6 +65,7 @@ func createhprose(cmd *commands.Command, args []string) int {
 	if err != nil {
 		iziLogger.Log.Fatalf("%s", err)
 	}
+	utils.ResolveDatabase(generate.SQLConnName.String(), &generate.SQLDriver, &generate.SQLConn, false)
 	if generate.SQLDriver == "" {
 		generate.SQLDriver = "mysql"
 	}
@@ -79,18 +81,20 @@ func createhprose(cmd *commands.Command, args []string) int {
 
 	if generate.SQLConn != "" {
 		iziLogger.Log.Infof("Using '%s' as 'driver'", generate.SQLDriver)
-		iziLogger.Log.Infof("Using '%s' as 'conn'", generate.SQLConn)
+		iziLogger.Log.Infof("Using '%s' as 'conn'", utils.MaskDSN(generate.SQLConn.String()))
 		iziLogger.Log.Infof("Using '%s' as 'tables'", generate.Tables)
 		generate.GenerateHproseAppcode(string(generate.SQLDriver), string(generate.SQLConn), "1", string(generate.Tables), path.Join(curpath, args[0]))
 
 		fmt.Fprintf(output, "\t%s%screate%s\t %s%s\n", "\x1b[32m", "\x1b[1m", "\x1b[21m", path.Join(apppath, "main.go"), "\x1b[0m")
 		maingoContent := strings.Replace(generate.HproseMainconngo, "{{.Appname}}", packpath, -1)
-		maingoContent = strings.Replace(maingoContent, "{{.DriverName}}", string(generate.SQLDriver), -1)
+		maingoContent = strings.Replace(maingoContent, "{{.DriverName}}", generate.SQLDriverName(string(generate.SQLDriver)), -1)
 		maingoContent = strings.Replace(maingoContent, "{{HproseFunctionList}}", strings.Join(generate.HproseAddFunctions, ""), -1)
 		if generate.SQLDriver == "mysql" {
 			maingoContent = strings.Replace(maingoContent, "{{.DriverPkg}}", `_ "github.com/go-sql-driver/mysql"`, -1)
 		} else if generate.SQLDriver == "postgres" {
 			maingoContent = strings.Replace(maingoContent, "{{.DriverPkg}}", `_ "github.com/lib/pq"`, -1)
+		} else if generate.SQLDriver == "sqlite" {
+			maingoContent = strings.Replace(maingoContent, "{{.DriverPkg}}", `_ "github.com/mattn/go-sqlite3"`, -1)
 		}
 		utils.WriteToFile(path.Join(apppath, "main.go"),
 			strings.Replace(
@@ -0,0 +1,163 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+
+	iziLogger "github.com/izi-global/izi/logger"
+)
+
+// checksum returns the SHA-256 of the files of the migration, recorded when it is applied.
+func (f *migrationFile) checksum(dir string) string {
+	h := sha256.New()
+	for _, file := range []string{f.GoFile, f.UpFile, f.DownFile} {
+		if file == "" {
+			continue
+		}
+		content, err := ioutil.ReadFile(path.Join(dir, file))
+		if err != nil {
+			iziLogger.Log.Fatalf("Could not read migration '%s': %s", file, err)
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", path.Ext(file), len(content))
+		h.Write(content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// drift is an applied migration whose file changed since it was applied.
+type drift struct {
+	record  *migrationRecord
+	file    *migrationFile // Nil when the file was deleted or renamed
+	renamed *migrationFile // The pending migration with the same checksum
+}
+
+func (d *drift) String() string {
+	switch {
+	case d.file != nil:
+		return fmt.Sprintf("Applied migration '%s' was modified", d.file.file())
+	case d.renamed != nil:
+		return fmt.Sprintf("Applied migration '%s' was renamed to '%s'", d.record.Name, d.renamed.file())
+	default:
+		return fmt.Sprintf("The file of applied migration '%s' was deleted", d.record.Name)
+	}
+}
+
+// drifts compares the applied migrations with their files. Migrations applied
+// before checksums were recorded are not checked until they are repaired.
+func (m *migrator) drifts(records map[string]*migrationRecord) []*drift {
+	var (
+		drifts  []*drift
+		pending = make(map[string]*migrationFile)
+	)
+	for _, f := range m.migrations {
+		if !records[f.Name].applied() {
+			pending[f.checksum(m.dir)] = f
+		}
+	}
+
+	for _, r := range m.appliedRecords(records) {
+		f := m.file(r.Name)
+		switch {
+		case r.Checksum == "":
+		case f != nil:
+			if f.checksum(m.dir) != r.Checksum {
+				drifts = append(drifts, &drift{record: r, file: f})
+			}
+		default:
+			drifts = append(drifts, &drift{record: r, renamed: pending[r.Checksum]})
+		}
+	}
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].record.ID < drifts[j].record.ID })
+	return drifts
+}
+
+// file returns the migration named name, or nil when its file is missing.
+func (m *migrator) file(name string) *migrationFile {
+	for _, f := range m.migrations {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// checkDrift warns about the applied migrations which changed, or stops the
+// migration with -strict.
+func (m *migrator) checkDrift() {
+	drifts := m.drifts(m.records())
+	for _, d := range drifts {
+		if mStrict {
+			iziLogger.Log.Error(d.String())
+		} else {
+			iziLogger.Log.Warn(d.String())
+		}
+	}
+	if len(drifts) == 0 {
+		return
+	}
+	iziLogger.Log.Hint("Run 'izi migrate repair' once the changes are deliberate")
+	if mStrict {
+		m.close()
+		os.Exit(2)
+	}
+}
+
+// MigrateRepair records the current checksum of the applied migrations, the
+// ones applied before checksums were recorded included. Renamed migrations are
+// recorded under their new name and the records of deleted ones are removed.
+func MigrateRepair(currpath, driver, connStr string) {
+	m := newMigrator(currpath, driver, connStr, true)
+	defer m.close()
+	if m.dryRun {
+		iziLogger.Log.Fatal("Repair does not support -dry-run")
+	}
+
+	records := m.records()
+	drifts := make(map[string]*drift)
+	for _, d := range m.drifts(records) {
+		drifts[d.record.Name] = d
+	}
+
+	updateQuery := bindVars(driver, "UPDATE migrations SET checksum = ? WHERE id_migration = ?")
+	for _, r := range m.appliedRecords(records) {
+		d, f := drifts[r.Name], m.file(r.Name)
+		switch {
+		case d != nil && d.renamed != nil:
+			query := bindVars(driver, "UPDATE migrations SET name = ?, checksum = ? WHERE name = ?")
+			if _, err := m.db.Exec(query, d.renamed.Name, r.Checksum, r.Name); err != nil {
+				iziLogger.Log.Fatalf("Could not repair migration '%s': %s", r.Name, err)
+			}
+			iziLogger.Log.Infof("|> Renamed '%s' to '%s'", r.Name, d.renamed.Name)
+		case d != nil && f == nil:
+			query := bindVars(driver, "DELETE FROM migrations WHERE name = ?")
+			if _, err := m.db.Exec(query, r.Name); err != nil {
+				iziLogger.Log.Fatalf("Could not repair migration '%s': %s", r.Name, err)
+			}
+			iziLogger.Log.Infof("|> Removed the records of deleted migration '%s'", r.Name)
+		case f != nil && (d != nil || r.Checksum == ""):
+			if _, err := m.db.Exec(updateQuery, f.checksum(m.dir), r.ID); err != nil {
+				iziLogger.Log.Fatalf("Could not repair migration '%s': %s", r.Name, err)
+			}
+			iziLogger.Log.Infof("|> Recorded the checksum of '%s'", f.file())
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package migrate
+
+import (
+	"io/ioutil"
+	"path"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+const (
+	createUsers = "CREATE TABLE users (id int);"
+	addIndex    = "CREATE INDEX idx_users_id ON users (id);"
+)
+
+// sqlChecksum returns the checksum of a SQL migration whose up file holds content.
+func sqlChecksum(t *testing.T, content string) string {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(path.Join(dir, "m.up.sql"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return (&migrationFile{UpFile: "m.up.sql"}).checksum(dir)
+}
+
+func TestDrifts(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string // Up script of the migrations on disk
+		records  []*migrationRecord
+		squashed map[string]string
+		want     []string
+	}{
+		{
+			name:  "unchanged",
+			files: map[string]string{"create_users": createUsers, "add_index": addIndex},
+			records: []*migrationRecord{
+				{ID: 1, Name: "create_users", Status: "update", Checksum: sqlChecksum(t, createUsers)},
+				{ID: 2, Name: "add_index", Status: "update", Checksum: sqlChecksum(t, addIndex)},
+			},
+		},
+		{
+			name:  "modified",
+			files: map[string]string{"create_users": "CREATE TABLE users (id bigint);"},
+			records: []*migrationRecord{
+				{ID: 1, Name: "create_users", Status: "update", Checksum: sqlChecksum(t, createUsers)},
+			},
+			want: []string{"Applied migration 'create_users.up.sql' was modified"},
+		},
+		{
+			name:  "deleted",
+			files: map[string]string{"add_index": addIndex},
+			records: []*migrationRecord{
+				{ID: 1, Name: "create_users", Status: "update", Checksum: sqlChecksum(t, createUsers)},
+			},
+			want: []string{"The file of applied migration 'create_users' was deleted"},
+		},
+		{
+			name:  "renamed",
+			files: map[string]string{"create_user_table": createUsers},
+			records: []*migrationRecord{
+				{ID: 1, Name: "create_users", Status: "update", Checksum: sqlChecksum(t, createUsers)},
+			},
+			want: []string{"Applied migration 'create_users' was renamed to 'create_user_table.up.sql'"},
+		},
+		{
+			name:  "renamed and modified",
+			files: map[string]string{"create_user_table": "CREATE TABLE users (id bigint);"},
+			records: []*migrationRecord{
+				{ID: 1, Name: "create_users", Status: "update", Checksum: sqlChecksum(t, createUsers)},
+			},
+			want: []string{"The file of applied migration 'create_users' was deleted"},
+		},
+		{
+			name:  "applied before checksums were recorded",
+			files: map[string]string{"create_users": "CREATE TABLE users (id bigint);"},
+			records: []*migrationRecord{
+				{ID: 1, Name: "create_users", Status: "update"},
+			},
+		},
+		{
+			name:  "rolled back",
+			files: map[string]string{"create_users": "CREATE TABLE users (id bigint);"},
+			records: []*migrationRecord{
+				{ID: 1, Name: "create_users", Status: "rollback", Checksum: sqlChecksum(t, createUsers)},
+			},
+		},
+		{
+			name:  "squashed",
+			files: map[string]string{"add_index": addIndex},
+			records: []*migrationRecord{
+				{ID: 1, Name: "create_users", Status: "update", Checksum: sqlChecksum(t, createUsers)},
+				{ID: 2, Name: "add_index", Status: "update", Checksum: sqlChecksum(t, addIndex)},
+			},
+			squashed: map[string]string{"create_users": "baseline"},
+		},
+		{
+			name:  "ordered by id",
+			files: map[string]string{"create_users": "", "add_index": ""},
+			records: []*migrationRecord{
+				{ID: 2, Name: "add_index", Status: "update", Checksum: sqlChecksum(t, addIndex)},
+				{ID: 1, Name: "create_users", Status: "update", Checksum: sqlChecksum(t, createUsers)},
+			},
+			want: []string{
+				"Applied migration 'create_users.up.sql' was modified",
+				"Applied migration 'add_index.up.sql' was modified",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &migrator{dir: t.TempDir(), squashed: tt.squashed}
+			var names []string
+			for name := range tt.files {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				f := &migrationFile{Name: name, UpFile: name + ".up.sql"}
+				if err := ioutil.WriteFile(path.Join(m.dir, f.UpFile), []byte(tt.files[name]), 0644); err != nil {
+					t.Fatal(err)
+				}
+				m.migrations = append(m.migrations, f)
+			}
+			records := make(map[string]*migrationRecord)
+			for _, r := range tt.records {
+				records[r.Name] = r
+			}
+
+			var got []string
+			for _, d := range m.drifts(records) {
+				got = append(got, d.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("drifts() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package migrate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	iziLogger "github.com/izi-global/izi/logger"
+)
+
+// dryRunRecords returns the records the dry run starts from. They are
+// updated as the migrations are simulated, nothing is written to the database.
+func (m *migrator) dryRunRecords() map[string]*migrationRecord {
+	if m.simulated != nil {
+		return m.simulated
+	}
+
+	m.simulated = make(map[string]*migrationRecord)
+	columns, err := migrationsTableColumns(m.db, m.driver)
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not show columns of migrations table: %s", err)
+	}
+	if len(columns) > 0 {
+		m.simulated = readMigrationRecords(m.db)
+	}
+	return m.simulated
+}
+
+// simulate records the migration as applied or rolled back in the dry run.
+func (m *migrator) simulate(name, status string) {
+	records := m.dryRunRecords()
+	var id int64
+	for _, r := range records {
+		if r.ID > id {
+			id = r.ID
+		}
+	}
+	records[name] = &migrationRecord{ID: id + 1, Name: name, Status: status}
+}
+
+// printScript adds the statements of a migration to the dry-run script, in a
+// transaction when they would run in one.
+func (m *migrator) printScript(direction string, f *migrationFile, s *migrationScript) {
+	fmt.Fprintf(&m.script, "-- %s %s (%s)\n", direction, f.Name, s.file)
+	for _, query := range s.queries {
+		fmt.Fprintf(&m.script, "-- WARNING: no rows returned to %s\n", strings.Join(strings.Fields(query), " "))
+	}
+	if len(s.statements) == 0 {
+		m.script.WriteString("-- no statements\n\n")
+		return
+	}
+	transaction := s.transaction && transactionalDDL(m.driver)
+	if transaction {
+		m.script.WriteString("BEGIN;\n")
+	}
+	for _, statement := range s.statements {
+		m.script.WriteString(strings.TrimSuffix(strings.TrimSpace(statement), ";") + ";\n")
+	}
+	if transaction {
+		m.script.WriteString("COMMIT;\n")
+	}
+	m.script.WriteString("\n")
+}
+
+// finishDryRun prints the dry-run script and writes it to the script file, if any.
+func (m *migrator) finishDryRun() {
+	header := fmt.Sprintf("-- izi migrate dry run on %s\n-- Generated at %s, nothing has been executed\n\n",
+		m.driver, time.Now().Format("2006-01-02 15:04:05"))
+	script := header + m.script.String()
+	if m.script.Len() == 0 {
+		script += "-- no migrations to run\n"
+	}
+
+	os.Stdout.WriteString(script)
+	if mScript != "" {
+		// Building the Go migrations changed the working directory
+		file := mScript
+		if !filepath.IsAbs(file) {
+			file = path.Join(m.currpath, file)
+		}
+		if err := ioutil.WriteFile(file, []byte(script), 0644); err != nil {
+			iziLogger.Log.Fatalf("Could not write the dry-run script: %s", err)
+		}
+		iziLogger.Log.Infof("Dry-run script written to '%s'", file)
+	}
+}
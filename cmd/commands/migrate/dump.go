@@ -0,0 +1,336 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/izi-global/izi/config"
+	iziLogger "github.com/izi-global/izi/logger"
+	"github.com/izi-global/izi/utils"
+)
+
+// Default file of the schema dumps, relative to the application
+const defaultSchemaFile = "database/schema.sql"
+
+// Tables of izi itself, left out of the dumps
+var bookkeepingTables = map[string]bool{"migrations": true, "migrations_lock": true}
+
+// Counter of the next AUTO_INCREMENT value, which changes with the data
+var autoIncrementRegex = regexp.MustCompile(` AUTO_INCREMENT=\d+`)
+
+// schemaDump is the DDL recreating the schema of a database.
+type schemaDump struct {
+	driver     string
+	tables     []string // In creation order
+	types      []string // Postgres enums
+	statements []string
+}
+
+// String returns the dump as a SQL script.
+func (d *schemaDump) String() string {
+	var b strings.Builder
+	for _, s := range d.statements {
+		b.WriteString(s + ";\n\n")
+	}
+	return b.String()
+}
+
+// dropStatements returns the statements dropping the dumped schema.
+func (d *schemaDump) dropStatements() []string {
+	var statements []string
+	for i := len(d.tables) - 1; i >= 0; i-- {
+		if d.driver == "postgres" {
+			statements = append(statements, "DROP TABLE IF EXISTS "+quoteIdent(d.driver, d.tables[i])+" CASCADE")
+		} else {
+			statements = append(statements, "DROP TABLE IF EXISTS "+quoteIdent(d.driver, d.tables[i]))
+		}
+	}
+	for _, t := range d.types {
+		statements = append(statements, "DROP TYPE IF EXISTS "+quoteIdent(d.driver, t))
+	}
+	return statements
+}
+
+// dumpSchema returns the tables, indexes, constraints and enums of the
+// database in a deterministic order. The migrations table is left out.
+func dumpSchema(db *sql.DB, driver string) (*schemaDump, error) {
+	d := &schemaDump{driver: driver}
+	var err error
+	switch driver {
+	case "postgres":
+		err = d.dumpPostgres(db)
+	case "sqlite":
+		err = d.dumpSQLite(db)
+	default:
+		err = d.dumpMySQL(db)
+	}
+	return d, err
+}
+
+func (d *schemaDump) dumpMySQL(db *sql.DB) error {
+	tables, err := queryStrings(db, "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_NAME")
+	if err != nil {
+		return err
+	}
+	rows, err := db.Query(`SELECT DISTINCT TABLE_NAME, REFERENCED_TABLE_NAME FROM information_schema.KEY_COLUMN_USAGE
+		WHERE TABLE_SCHEMA = DATABASE() AND REFERENCED_TABLE_NAME IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	deps := make(map[string][]string)
+	for rows.Next() {
+		var table, referenced string
+		if err := rows.Scan(&table, &referenced); err != nil {
+			rows.Close()
+			return err
+		}
+		deps[table] = append(deps[table], referenced)
+	}
+	rows.Close()
+
+	// Tables are created after the tables their foreign keys reference
+	for _, table := range utils.SortByDependencies(tables, deps) {
+		if bookkeepingTables[table] {
+			continue
+		}
+		var name, create string
+		if err := db.QueryRow("SHOW CREATE TABLE "+quoteIdent("mysql", table)).Scan(&name, &create); err != nil {
+			return err
+		}
+		d.tables = append(d.tables, table)
+		d.statements = append(d.statements, autoIncrementRegex.ReplaceAllString(create, ""))
+	}
+	return nil
+}
+
+func (d *schemaDump) dumpPostgres(db *sql.DB) error {
+	// Enums
+	rows, err := db.Query(`SELECT t.typname, e.enumlabel FROM pg_type t
+		JOIN pg_enum e ON e.enumtypid = t.oid
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		WHERE n.nspname = current_schema() AND t.typname <> 'migrations_status'
+		ORDER BY t.typname, e.enumsortorder`)
+	if err != nil {
+		return err
+	}
+	labels := make(map[string][]string)
+	for rows.Next() {
+		var name, label string
+		if err := rows.Scan(&name, &label); err != nil {
+			rows.Close()
+			return err
+		}
+		if _, ok := labels[name]; !ok {
+			d.types = append(d.types, name)
+		}
+		labels[name] = append(labels[name], "'"+strings.Replace(label, "'", "''", -1)+"'")
+	}
+	rows.Close()
+	for _, t := range d.types {
+		d.statements = append(d.statements, fmt.Sprintf("CREATE TYPE %s AS ENUM (%s)", quoteIdent("postgres", t), strings.Join(labels[t], ", ")))
+	}
+
+	tables, err := queryStrings(db, "SELECT tablename FROM pg_tables WHERE schemaname = current_schema() ORDER BY tablename")
+	if err != nil {
+		return err
+	}
+	// Foreign keys are added once every table exists
+	var foreignKeys, indexes []string
+	for _, table := range tables {
+		if bookkeepingTables[table] {
+			continue
+		}
+		oid := "(SELECT c.oid FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace WHERE n.nspname = current_schema() AND c.relname = $1)"
+
+		var lines []string
+		rows, err := db.Query(`SELECT a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull, COALESCE(pg_get_expr(d.adbin, d.adrelid), '')
+			FROM pg_attribute a LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+			WHERE a.attrelid = `+oid+` AND a.attnum > 0 AND NOT a.attisdropped ORDER BY a.attnum`, table)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var (
+				name, typ, def string
+				notNull        bool
+			)
+			if err := rows.Scan(&name, &typ, &notNull, &def); err != nil {
+				rows.Close()
+				return err
+			}
+			if strings.HasPrefix(def, "nextval(") {
+				// Columns owning a sequence are dumped as serial
+				switch typ {
+				case "integer":
+					typ, def = "serial", ""
+				case "bigint":
+					typ, def = "bigserial", ""
+				case "smallint":
+					typ, def = "smallserial", ""
+				}
+			}
+			line := quoteIdent("postgres", name) + " " + typ
+			if notNull {
+				line += " NOT NULL"
+			}
+			if def != "" {
+				line += " DEFAULT " + def
+			}
+			lines = append(lines, line)
+		}
+		rows.Close()
+
+		rows, err = db.Query("SELECT conname, contype, pg_get_constraintdef(oid) FROM pg_constraint WHERE conrelid = "+oid+" ORDER BY contype, conname", table)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var name, typ, def string
+			if err := rows.Scan(&name, &typ, &def); err != nil {
+				rows.Close()
+				return err
+			}
+			if typ == "f" {
+				foreignKeys = append(foreignKeys, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s", quoteIdent("postgres", table), quoteIdent("postgres", name), def))
+				continue
+			}
+			lines = append(lines, "CONSTRAINT "+quoteIdent("postgres", name)+" "+def)
+		}
+		rows.Close()
+
+		tableIndexes, err := queryStrings(db, `SELECT indexdef FROM pg_indexes WHERE schemaname = current_schema() AND tablename = $1
+			AND indexname NOT IN (SELECT conname FROM pg_constraint WHERE conrelid = `+oid+`) ORDER BY indexname`, table)
+		if err != nil {
+			return err
+		}
+		indexes = append(indexes, tableIndexes...)
+
+		d.tables = append(d.tables, table)
+		d.statements = append(d.statements, fmt.Sprintf("CREATE TABLE %s (\n\t%s\n)", quoteIdent("postgres", table), strings.Join(lines, ",\n\t")))
+	}
+	d.statements = append(d.statements, indexes...)
+	d.statements = append(d.statements, foreignKeys...)
+	return nil
+}
+
+func (d *schemaDump) dumpSQLite(db *sql.DB) error {
+	rows, err := db.Query(`SELECT type, name, tbl_name, sql FROM sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+		ORDER BY CASE type WHEN 'table' THEN 0 WHEN 'index' THEN 1 WHEN 'view' THEN 2 ELSE 3 END, tbl_name, name`)
+	if err != nil {
+		return err
+	}
+	var (
+		tables []string
+		create = make(map[string]string)
+		others []string // Indexes, views and triggers
+	)
+	for rows.Next() {
+		var typ, name, table, statement string
+		if err := rows.Scan(&typ, &name, &table, &statement); err != nil {
+			rows.Close()
+			return err
+		}
+		if bookkeepingTables[table] {
+			continue
+		}
+		if typ == "table" {
+			tables = append(tables, name)
+			create[name] = statement
+		} else {
+			others = append(others, statement)
+		}
+	}
+	rows.Close()
+
+	deps := make(map[string][]string)
+	for _, table := range tables {
+		referenced, err := queryStrings(db, `SELECT DISTINCT "table" FROM pragma_foreign_key_list(?)`, table)
+		if err != nil {
+			return err
+		}
+		deps[table] = referenced
+	}
+	for _, table := range utils.SortByDependencies(tables, deps) {
+		d.tables = append(d.tables, table)
+		d.statements = append(d.statements, create[table])
+	}
+	d.statements = append(d.statements, others...)
+	return nil
+}
+
+// queryStrings returns the first column of the rows of the query.
+func queryStrings(db *sql.DB, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// MigrateDump writes the schema of the database to output or, when empty,
+// to database.schema_file, database/schema.sql by default.
+func MigrateDump(currpath, driver, connStr, output string) {
+	if output == "" {
+		output = config.Conf.Database.SchemaFile
+	}
+	if output == "" {
+		output = defaultSchemaFile
+	}
+	if !filepath.IsAbs(output) {
+		output = path.Join(currpath, output)
+	}
+
+	m := newMigrator(currpath, driver, connStr, false)
+	defer m.close()
+	dump, err := dumpSchema(m.db, driver)
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not dump the database schema: %s", err)
+	}
+
+	content := fmt.Sprintf("-- Schema of the %s database, generated by izi migrate dump. Do not edit.\n\n", driver) + dump.String()
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		iziLogger.Log.Fatalf("Could not create directory: %s", err)
+	}
+	if err := ioutil.WriteFile(output, []byte(content), 0644); err != nil {
+		iziLogger.Log.Fatalf("Could not write the schema: %s", err)
+	}
+	iziLogger.Log.Infof("Schema written to '%s'", output)
+}
+
+// quoteIdent quotes a table or a column name for the driver.
+func quoteIdent(driver, name string) string {
+	if driver == "mysql" {
+		return "`" + strings.Replace(name, "`", "``", -1) + "`"
+	}
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
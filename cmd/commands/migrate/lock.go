@@ -0,0 +1,284 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/izi-global/izi/config"
+	iziLogger "github.com/izi-global/izi/logger"
+	"github.com/izi-global/izi/utils"
+)
+
+// Name of the lock taken while migrating
+const migrationLockName = "izi_migrations"
+
+// Interval between two attempts to take the lock
+const lockRetryInterval = 500 * time.Millisecond
+
+// MySQL locks are server wide, the lock name includes the database
+const mysqlLockName = "CONCAT(IFNULL(DATABASE(), ''), ':', ?)"
+
+// Key of the Postgres advisory lock
+var migrationLockKey = int64(crc32.ChecksumIEEE([]byte(migrationLockName)))
+
+// SQL of the lock table used by the databases without session locks
+const lockTableDDL = `
+CREATE TABLE IF NOT EXISTS migrations_lock (
+	id integer PRIMARY KEY,
+	owner varchar(255) NOT NULL,
+	locked_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+// migrationLock is a database level lock preventing concurrent migrations.
+// MySQL and Postgres locks belong to the session of conn and are released
+// when it ends, other databases use the migrations_lock table.
+type migrationLock struct {
+	conn   *sql.Conn
+	driver string
+	owner  string
+}
+
+// lockTimeout returns how long to wait for the migration lock.
+func lockTimeout() time.Duration {
+	value := mLockTimeout
+	if value == "" {
+		value = config.Conf.Database.LockTimeout
+	}
+	if value == "" {
+		return time.Minute
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		iziLogger.Log.Fatalf("Invalid lock timeout '%s': %s", value, err)
+	}
+	return timeout
+}
+
+// lockOwner identifies this process in the lock table.
+func lockOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+// acquireLock takes the migration lock, waiting up to timeout for the current holder.
+func acquireLock(db *sql.DB, driver string, timeout time.Duration) *migrationLock {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not connect to database: %s", err)
+	}
+	l := &migrationLock{conn: conn, driver: driver, owner: lockOwner()}
+
+	acquired := false
+	switch driver {
+	case "mysql":
+		acquired, err = l.getLock(timeout)
+	case "postgres":
+		acquired, err = l.retry(timeout, l.tryAdvisoryLock)
+	default:
+		if _, err = conn.ExecContext(context.Background(), lockTableDDL); err == nil {
+			acquired, err = l.retry(timeout, l.tryLockTable)
+		}
+	}
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not acquire the migration lock: %s", err)
+	}
+	if !acquired {
+		iziLogger.Log.Errorf("Could not acquire the migration lock within %s, it is held by %s", timeout, l.holder())
+		if driver != "mysql" && driver != "postgres" {
+			iziLogger.Log.Hint("Run 'izi migrate unlock' if that process is no longer running")
+		}
+		os.Exit(2)
+	}
+	return l
+}
+
+// retry calls try until it takes the lock or the timeout expires.
+func (l *migrationLock) retry(timeout time.Duration, try func() (bool, error)) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	for waited := false; ; waited = true {
+		acquired, err := try()
+		if acquired || err != nil {
+			return acquired, err
+		}
+		if !waited {
+			iziLogger.Log.Infof("Waiting for the migration lock held by %s...", l.holder())
+		}
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+func (l *migrationLock) getLock(timeout time.Duration) (bool, error) {
+	ctx := context.Background()
+	var free sql.NullInt64
+	if err := l.conn.QueryRowContext(ctx, "SELECT IS_FREE_LOCK("+mysqlLockName+")", migrationLockName).Scan(&free); err != nil {
+		return false, err
+	}
+	if free.Valid && free.Int64 == 0 {
+		iziLogger.Log.Infof("Waiting for the migration lock held by %s...", l.holder())
+	}
+
+	var result sql.NullInt64
+	seconds := int(timeout / time.Second)
+	err := l.conn.QueryRowContext(ctx, "SELECT GET_LOCK("+mysqlLockName+", ?)", migrationLockName, seconds).Scan(&result)
+	return result.Valid && result.Int64 == 1, err
+}
+
+func (l *migrationLock) tryAdvisoryLock() (bool, error) {
+	var acquired bool
+	err := l.conn.QueryRowContext(context.Background(), "SELECT pg_try_advisory_lock($1)", migrationLockKey).Scan(&acquired)
+	return acquired, err
+}
+
+func (l *migrationLock) tryLockTable() (bool, error) {
+	ctx := context.Background()
+	query := bindVars(l.driver, "INSERT INTO migrations_lock (id, owner) VALUES (1, ?)")
+	if _, err := l.conn.ExecContext(ctx, query, l.owner); err == nil {
+		return true, nil
+	}
+
+	// The lock is held, take it over if its owner is gone
+	var owner string
+	err := l.conn.QueryRowContext(ctx, "SELECT owner FROM migrations_lock WHERE id = 1").Scan(&owner)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !ownerRunning(owner) {
+		iziLogger.Log.Warnf("Removing the migration lock left by %s", owner)
+		query := bindVars(l.driver, "DELETE FROM migrations_lock WHERE id = 1 AND owner = ?")
+		if _, err := l.conn.ExecContext(ctx, query, owner); err != nil {
+			return false, err
+		}
+		return l.tryLockTable()
+	}
+	return false, nil
+}
+
+// holder describes the session or the process holding the lock.
+func (l *migrationLock) holder() string {
+	ctx := context.Background()
+	switch l.driver {
+	case "mysql":
+		var id sql.NullInt64
+		if err := l.conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK("+mysqlLockName+")", migrationLockName).Scan(&id); err != nil || !id.Valid {
+			return "an unknown session"
+		}
+		var user, host string
+		query := "SELECT USER, HOST FROM information_schema.PROCESSLIST WHERE ID = ?"
+		if err := l.conn.QueryRowContext(ctx, query, id.Int64).Scan(&user, &host); err != nil {
+			return fmt.Sprintf("connection %d", id.Int64)
+		}
+		return fmt.Sprintf("connection %d (%s@%s)", id.Int64, user, host)
+	case "postgres":
+		var (
+			pid                   int
+			user, client, appName string
+			backendStart          time.Time
+		)
+		query := `SELECT a.pid, a.usename, COALESCE(host(a.client_addr), 'local'), a.application_name, a.backend_start
+			FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid
+			WHERE l.locktype = 'advisory' AND l.objid::bigint = $1 AND l.granted
+			AND l.database = (SELECT oid FROM pg_database WHERE datname = current_database())`
+		if err := l.conn.QueryRowContext(ctx, query, migrationLockKey).Scan(&pid, &user, &client, &appName, &backendStart); err != nil {
+			return "an unknown session"
+		}
+		return fmt.Sprintf("backend %d (%s@%s %s, connected at %s)", pid, user, client, appName, backendStart.Format("2006-01-02 15:04:05"))
+	default:
+		var owner, lockedAt []byte
+		if err := l.conn.QueryRowContext(ctx, "SELECT owner, locked_at FROM migrations_lock WHERE id = 1").Scan(&owner, &lockedAt); err != nil {
+			return "an unknown process"
+		}
+		return fmt.Sprintf("%s since %s", owner, formatRecordTime(string(lockedAt)))
+	}
+}
+
+// release releases the lock and closes its connection.
+func (l *migrationLock) release() {
+	ctx := context.Background()
+	var err error
+	switch l.driver {
+	case "mysql":
+		_, err = l.conn.ExecContext(ctx, "SELECT RELEASE_LOCK("+mysqlLockName+")", migrationLockName)
+	case "postgres":
+		_, err = l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey)
+	default:
+		_, err = l.conn.ExecContext(ctx, bindVars(l.driver, "DELETE FROM migrations_lock WHERE id = 1 AND owner = ?"), l.owner)
+	}
+	if err != nil {
+		iziLogger.Log.Warnf("Could not release the migration lock: %s", err)
+	}
+	l.conn.Close()
+}
+
+// ownerRunning reports whether the process owning the lock table may still
+// be running. Only processes of this host can be checked.
+func ownerRunning(owner string) bool {
+	i := strings.LastIndex(owner, ":")
+	if i < 0 {
+		return true
+	}
+	host, _ := os.Hostname()
+	pid, err := strconv.Atoi(owner[i+1:])
+	if err != nil || owner[:i] != host {
+		return true
+	}
+
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		// FindProcess fails on Windows when the process does not exist
+		return true
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+// MigrateUnlock removes the lock left in the lock table by a migration which
+// did not complete. MySQL and Postgres locks end with the session holding them.
+func MigrateUnlock(currpath, driver, connStr string) {
+	db, err := sql.Open(sqlDriverName(driver), connStr)
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not connect to database using '%s': %s", utils.MaskDSN(connStr), err)
+	}
+	defer db.Close()
+
+	if driver == "mysql" || driver == "postgres" {
+		iziLogger.Log.Infof("The %s migration lock is released when the session holding it ends", driver)
+		return
+	}
+	if _, err := db.Exec(lockTableDDL); err != nil {
+		iziLogger.Log.Fatalf("Could not remove the migration lock: %s", err)
+	}
+	if _, err := db.Exec("DELETE FROM migrations_lock"); err != nil {
+		iziLogger.Log.Fatalf("Could not remove the migration lock: %s", err)
+	}
+	iziLogger.Log.Info("Migration lock removed")
+}
@@ -0,0 +1,229 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package migrate
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	iziLogger "github.com/izi-global/izi/logger"
+	"github.com/izi-global/izi/utils"
+)
+
+// Layout of the timestamp prefixing the migration files
+const migrationDateFormat = "20060102_150405"
+
+var (
+	// 20180102_150405_create_user.go
+	goMigrationRegex = regexp.MustCompile(`^(\d{8}_?\d{6})_(\w+)\.go$`)
+	// 20180102_150405_create_user.up.sql and 20180102_150405_create_user.down.sql
+	sqlMigrationRegex = regexp.MustCompile(`^(\d{8}_?\d{6})_(\w+)\.(up|down)\.sql$`)
+)
+
+// migrationFile is a Go or a SQL migration found in database/migrations.
+type migrationFile struct {
+	Name     string // Name recorded in the migrations table
+	Created  time.Time
+	GoFile   string // Source file of a Go migration
+	UpFile   string // Files of a SQL migration
+	DownFile string
+}
+
+func (m *migrationFile) isSQL() bool {
+	return m.GoFile == ""
+}
+
+// file returns the file applying the migration.
+func (m *migrationFile) file() string {
+	if m.isSQL() {
+		return m.UpFile
+	}
+	return m.GoFile
+}
+
+// migrationRecord is the latest row of a migration in the migrations table.
+type migrationRecord struct {
+	ID        int64
+	Name      string
+	Status    string
+	CreatedAt string
+	Error     string // Error of the last failed attempt, if any
+	Checksum  string // Checksum of the files when the migration was applied
+}
+
+func (r *migrationRecord) applied() bool {
+	return r != nil && r.Status == "update"
+}
+
+// failed reports whether the last attempt to apply the migration failed.
+func (r *migrationRecord) failed() bool {
+	return r != nil && r.Status == "" && r.Error != ""
+}
+
+// loadMigrations lists the Go and SQL migrations of the directory in timestamp order.
+func loadMigrations(dir string) []*migrationFile {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not find migration directory: %s", err)
+	}
+
+	var migrations []*migrationFile
+	sqlMigrations := make(map[string]*migrationFile)
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if m := goMigrationRegex.FindStringSubmatch(f.Name()); m != nil {
+			created := parseMigrationTime(f.Name(), m[1])
+			migrations = append(migrations, &migrationFile{
+				// Go migrations are registered under the name of their struct
+				Name:    utils.CamelCase(m[2]) + "_" + created.Format(migrationDateFormat),
+				Created: created,
+				GoFile:  f.Name(),
+			})
+			continue
+		}
+		if m := sqlMigrationRegex.FindStringSubmatch(f.Name()); m != nil {
+			created := parseMigrationTime(f.Name(), m[1])
+			name := m[2] + "_" + created.Format(migrationDateFormat)
+			migration, ok := sqlMigrations[name]
+			if !ok {
+				migration = &migrationFile{Name: name, Created: created}
+				sqlMigrations[name] = migration
+				migrations = append(migrations, migration)
+			}
+			if m[3] == "up" {
+				migration.UpFile = f.Name()
+			} else {
+				migration.DownFile = f.Name()
+			}
+		}
+	}
+
+	for _, m := range sqlMigrations {
+		if m.UpFile == "" {
+			iziLogger.Log.Fatalf("Migration '%s' has a down file but no up file", m.DownFile)
+		}
+	}
+
+	sort.SliceStable(migrations, func(i, j int) bool {
+		if migrations[i].Created.Equal(migrations[j].Created) {
+			return migrations[i].Name < migrations[j].Name
+		}
+		return migrations[i].Created.Before(migrations[j].Created)
+	})
+	return migrations
+}
+
+func parseMigrationTime(file, value string) time.Time {
+	t, err := time.Parse(migrationDateFormat, value[:8]+"_"+strings.TrimPrefix(value[8:], "_"))
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not parse the time of migration '%s': %s", file, err)
+	}
+	return t
+}
+
+// goHelperFiles lists the Go files of the directory which are not migrations,
+// they are built along with the Go migrations.
+func goHelperFiles(dir, binary string) []string {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not find migration directory: %s", err)
+	}
+
+	var helpers []string
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if name == binary+".go" || goMigrationRegex.MatchString(name) {
+			continue
+		}
+		helpers = append(helpers, name)
+	}
+	return helpers
+}
+
+// readMigrationRecords returns the latest record of every migration.
+func readMigrationRecords(db *sql.DB) map[string]*migrationRecord {
+	// The oldest tables recorded the applied migrations only
+	status := "'update'"
+	if migrationColumnExists(db, "status") {
+		status = "status"
+	}
+	rows, err := db.Query("SELECT id_migration, name, " + status + ", created_at, " + optionalColumn(db, "error_message") + ", " +
+		optionalColumn(db, "checksum") + " FROM migrations ORDER BY id_migration")
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not retrieve migrations: %s", err)
+	}
+	defer rows.Close()
+
+	records := make(map[string]*migrationRecord)
+	for rows.Next() {
+		var (
+			r                                         migrationRecord
+			name, status, createdAt, errMsg, checksum []byte
+		)
+		if err := rows.Scan(&r.ID, &name, &status, &createdAt, &errMsg, &checksum); err != nil {
+			iziLogger.Log.Fatalf("Could not read migrations in database: %s", err)
+		}
+		r.Name, r.Status, r.CreatedAt = string(name), string(status), string(createdAt)
+		r.Error, r.Checksum = string(errMsg), string(checksum)
+		records[r.Name] = &r
+	}
+	if err := rows.Err(); err != nil {
+		iziLogger.Log.Fatalf("Could not read migrations in database: %s", err)
+	}
+	return records
+}
+
+// pendingMigrations returns the migrations which are not applied, in timestamp order.
+func pendingMigrations(migrations []*migrationFile, records map[string]*migrationRecord) []*migrationFile {
+	var pending []*migrationFile
+	for _, m := range migrations {
+		if !records[m.Name].applied() {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// appliedRecords returns the records of the applied migrations, the most recently applied first.
+func appliedRecords(records map[string]*migrationRecord) []*migrationRecord {
+	var applied []*migrationRecord
+	for _, r := range records {
+		if r.applied() {
+			applied = append(applied, r)
+		}
+	}
+	sort.Slice(applied, func(i, j int) bool { return applied[i].ID > applied[j].ID })
+	return applied
+}
+
+// findMigration returns the file of the migration recorded under the name.
+func findMigration(migrations []*migrationFile, name string) *migrationFile {
+	for _, m := range migrations {
+		if m.Name == name {
+			return m
+		}
+	}
+	iziLogger.Log.Fatalf("Could not find the file of migration '%s'", name)
+	return nil
+}
@@ -0,0 +1,231 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	iziLogger "github.com/izi-global/izi/logger"
+)
+
+// Marks a migration which must not run in a transaction, e.g. for CREATE INDEX CONCURRENTLY
+var noTransactionRegex = regexp.MustCompile(`(?m)^\s*--\s*izi:no-transaction\s*$`)
+
+// Line of a Go migration opting out of the transaction
+var goNoTransactionRegex = regexp.MustCompile(`(?m)^\s*//\s*izi:no-transaction\s*$`)
+
+// migrationScript holds the statements run by one direction of a migration.
+type migrationScript struct {
+	file        string // File the statements come from
+	content     string // Recorded in the migrations table
+	statements  []string
+	transaction bool     // False when the migration opts out with izi:no-transaction
+	queries     []string // Queries run by a captured Go migration, which returned no rows
+	squashes    []string // Migrations replaced by a baseline
+}
+
+// readSQLScript reads the statements of a SQL migration file.
+func readSQLScript(dir, file string) *migrationScript {
+	content, err := ioutil.ReadFile(path.Join(dir, file))
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not read migration '%s': %s", file, err)
+	}
+	s := &migrationScript{
+		file:        file,
+		content:     string(content),
+		statements:  splitStatements(string(content)),
+		transaction: !noTransactionRegex.Match(content),
+	}
+	for _, m := range squashesRegex.FindAllStringSubmatch(s.content, -1) {
+		s.squashes = append(s.squashes, m[1])
+	}
+	return s
+}
+
+// transactionalDDL reports whether the schema changes of the driver can be rolled back.
+func transactionalDDL(driver string) bool {
+	return driver == "postgres" || driver == "sqlite"
+}
+
+// execer is implemented by *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// migrationError reports the statement of a migration which failed.
+type migrationError struct {
+	file        string
+	index       int // Position of the statement in the script, from 1
+	statement   string
+	transaction bool
+	err         error
+}
+
+func (e *migrationError) Error() string {
+	if e.index == 0 {
+		// The position is unknown for the statements of Go migrations
+		return fmt.Sprintf("%s: statement failed: %s", e.file, e.err)
+	}
+	return fmt.Sprintf("%s: statement %d failed: %s", e.file, e.index, e.err)
+}
+
+// execScript runs the statements of the script then record, which records the
+// migration. They run in a single transaction when the driver supports
+// transactional DDL, unless the script opts out.
+func execScript(db *sql.DB, driver string, s *migrationScript, record func(execer) error) error {
+	var (
+		ex execer = db
+		tx *sql.Tx
+	)
+	if s.transaction && transactionalDDL(driver) {
+		var err error
+		if tx, err = db.Begin(); err != nil {
+			return fmt.Errorf("could not begin transaction: %s", err)
+		}
+		ex = tx
+	}
+
+	for i, statement := range s.statements {
+		if _, err := ex.Exec(statement); err != nil {
+			if tx != nil {
+				tx.Rollback()
+			}
+			return &migrationError{file: s.file, index: i + 1, statement: statement, transaction: tx != nil, err: err}
+		}
+	}
+	if err := record(ex); err != nil {
+		if tx != nil {
+			tx.Rollback()
+		}
+		return fmt.Errorf("could not record migration: %s", err)
+	}
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("could not commit transaction: %s", err)
+		}
+	}
+	return nil
+}
+
+// bindVars rewrites the '?' placeholders of the query for the driver.
+func bindVars(driver, query string) string {
+	if driver != "postgres" {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// splitStatements splits a SQL script on the semicolons ending its statements.
+// Semicolons in quotes, comments and Postgres dollar-quoted bodies are kept.
+// Statements made only of comments are dropped.
+func splitStatements(script string) []string {
+	var (
+		statements []string
+		start      int
+		hasCode    bool
+	)
+	flush := func(end int) {
+		if hasCode {
+			statements = append(statements, strings.TrimSpace(script[start:end]))
+		}
+		start, hasCode = end+1, false
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			if j := strings.IndexByte(script[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(script)
+			}
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			if j := strings.Index(script[i+2:], "*/"); j >= 0 {
+				i += j + 3
+			} else {
+				i = len(script)
+			}
+		case c == '\'' || c == '"' || c == '`':
+			hasCode = true
+			i = skipQuoted(script, i, c)
+		case c == '$':
+			hasCode = true
+			if tag := dollarTag(script[i:]); tag != "" {
+				if j := strings.Index(script[i+len(tag):], tag); j >= 0 {
+					i += len(tag) + j + len(tag) - 1
+				} else {
+					i = len(script)
+				}
+			}
+		case c == ';':
+			flush(i)
+		case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			hasCode = true
+		}
+	}
+	if start < len(script) {
+		flush(len(script))
+	}
+	return statements
+}
+
+// skipQuoted returns the index of the quote closing the one at i.
+// Doubled quotes and backslash escapes do not close it.
+func skipQuoted(script string, i int, quote byte) int {
+	for j := i + 1; j < len(script); j++ {
+		switch script[j] {
+		case '\\':
+			j++
+		case quote:
+			if j+1 < len(script) && script[j+1] == quote {
+				j++
+				continue
+			}
+			return j
+		}
+	}
+	return len(script)
+}
+
+// dollarTag returns the Postgres dollar quote tag ($$ or $tag$) starting the string.
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == '$' {
+			return s[:i+1]
+		}
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9') {
+			return ""
+		}
+	}
+	return ""
+}
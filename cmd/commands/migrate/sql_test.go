@@ -0,0 +1,135 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package migrate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "empty",
+			script: "",
+			want:   nil,
+		},
+		{
+			name:   "statements",
+			script: "CREATE TABLE a (id int);\nCREATE TABLE b (id int);\n",
+			want:   []string{"CREATE TABLE a (id int)", "CREATE TABLE b (id int)"},
+		},
+		{
+			name:   "last statement without semicolon",
+			script: "DELETE FROM a;\nDELETE FROM b",
+			want:   []string{"DELETE FROM a", "DELETE FROM b"},
+		},
+		{
+			name:   "empty statements",
+			script: ";;\n  ;DELETE FROM a;;",
+			want:   []string{"DELETE FROM a"},
+		},
+		{
+			name:   "single quotes",
+			script: "INSERT INTO a VALUES ('x;y');INSERT INTO a VALUES ('z')",
+			want:   []string{"INSERT INTO a VALUES ('x;y')", "INSERT INTO a VALUES ('z')"},
+		},
+		{
+			name:   "doubled quotes",
+			script: "INSERT INTO a VALUES ('it''s; fine');SELECT 1",
+			want:   []string{"INSERT INTO a VALUES ('it''s; fine')", "SELECT 1"},
+		},
+		{
+			name:   "backslash escaped quotes",
+			script: `INSERT INTO a VALUES ('it\'s; fine');SELECT 1`,
+			want:   []string{`INSERT INTO a VALUES ('it\'s; fine')`, "SELECT 1"},
+		},
+		{
+			name:   "escaped backslash before the closing quote",
+			script: `INSERT INTO a VALUES ('C:\\');SELECT 1`,
+			want:   []string{`INSERT INTO a VALUES ('C:\\')`, "SELECT 1"},
+		},
+		{
+			name:   "double quotes and backticks",
+			script: "SELECT \"a;b\" FROM `c;d`;SELECT 1",
+			want:   []string{"SELECT \"a;b\" FROM `c;d`", "SELECT 1"},
+		},
+		{
+			name:   "line comments",
+			script: "-- first; statement\nSELECT 1; -- trailing; comment\nSELECT 2;",
+			want:   []string{"-- first; statement\nSELECT 1", "-- trailing; comment\nSELECT 2"},
+		},
+		{
+			name:   "block comments",
+			script: "SELECT /* a; b */ 1;/* only; a comment */",
+			want:   []string{"SELECT /* a; b */ 1"},
+		},
+		{
+			name:   "comment only",
+			script: "-- nothing to run;\n/* at all; */\n",
+			want:   nil,
+		},
+		{
+			name:   "dollar quoted body",
+			script: "CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql;SELECT f();",
+			want:   []string{"CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql", "SELECT f()"},
+		},
+		{
+			name:   "tagged dollar quotes",
+			script: "DO $body$ BEGIN PERFORM 'a;$$'; END $body$;SELECT 1",
+			want:   []string{"DO $body$ BEGIN PERFORM 'a;$$'; END $body$", "SELECT 1"},
+		},
+		{
+			name:   "positional parameters",
+			script: "PREPARE p AS SELECT $1; EXECUTE p(1);",
+			want:   []string{"PREPARE p AS SELECT $1", "EXECUTE p(1)"},
+		},
+		{
+			name:   "unterminated quote",
+			script: "SELECT 'a;b",
+			want:   []string{"SELECT 'a;b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements(%q) = %q, want %q", tt.script, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindVars(t *testing.T) {
+	tests := []struct {
+		driver string
+		query  string
+		want   string
+	}{
+		{"mysql", "UPDATE migrations SET status = ? WHERE id_migration = ?", "UPDATE migrations SET status = ? WHERE id_migration = ?"},
+		{"sqlite", "DELETE FROM migrations WHERE name = ?", "DELETE FROM migrations WHERE name = ?"},
+		{"postgres", "UPDATE migrations SET status = ? WHERE id_migration = ?", "UPDATE migrations SET status = $1 WHERE id_migration = $2"},
+		{"postgres", "SELECT name FROM migrations", "SELECT name FROM migrations"},
+		{"postgres", "INSERT INTO migrations (name, statements) VALUES (?, ?)", "INSERT INTO migrations (name, statements) VALUES ($1, $2)"},
+	}
+	for _, tt := range tests {
+		if got := bindVars(tt.driver, tt.query); got != tt.want {
+			t.Errorf("bindVars(%q, %q) = %q, want %q", tt.driver, tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package migrate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	iziLogger "github.com/izi-global/izi/logger"
+)
+
+// Directory of database/migrations receiving the squashed migration files
+const squashedDir = "squashed"
+
+// Lines of a baseline naming the migrations it replaces
+var squashesRegex = regexp.MustCompile(`(?m)^--\s*izi:squashes\s+(\S+)\s*$`)
+
+// loadSquashed returns the migrations replaced by a baseline, mapped to the
+// name of the baseline. Baselines are the SQL migrations named baseline.
+func loadSquashed(dir string, migrations []*migrationFile) map[string]string {
+	squashed := make(map[string]string)
+	for _, f := range migrations {
+		if !f.isSQL() || !strings.HasPrefix(f.Name, "baseline_") {
+			continue
+		}
+		content, err := ioutil.ReadFile(path.Join(dir, f.UpFile))
+		if err != nil {
+			iziLogger.Log.Fatalf("Could not read migration '%s': %s", f.UpFile, err)
+		}
+		for _, m := range squashesRegex.FindAllStringSubmatch(string(content), -1) {
+			squashed[m[1]] = f.Name
+		}
+	}
+	return squashed
+}
+
+// appliedRecords returns the records of the applied migrations, the most
+// recently applied first. The migrations replaced by a baseline are left out,
+// they are rolled back with it.
+func (m *migrator) appliedRecords(records map[string]*migrationRecord) []*migrationRecord {
+	var applied []*migrationRecord
+	for _, r := range appliedRecords(records) {
+		if m.squashed[r.Name] == "" {
+			applied = append(applied, r)
+		}
+	}
+	return applied
+}
+
+// baselineApplied reports whether the database already ran the migrations
+// replaced by the baseline, in which case the baseline is only recorded.
+func (m *migrator) baselineApplied(f *migrationFile, s *migrationScript) bool {
+	if len(s.squashes) == 0 {
+		return false
+	}
+	records := m.records()
+	applied := 0
+	for _, name := range s.squashes {
+		if records[name].applied() {
+			applied++
+		}
+	}
+	switch applied {
+	case 0:
+		return false
+	case len(s.squashes):
+		return true
+	}
+	iziLogger.Log.Errorf("The database applied %d of the %d migrations squashed into '%s'", applied, len(s.squashes), f.Name)
+	iziLogger.Log.Hint("Restore the squashed migrations from database/migrations/squashed and run them first")
+	m.close()
+	os.Exit(2)
+	return false
+}
+
+// MigrateSquash replaces the migrations up to the one named by until with a
+// baseline SQL migration holding the schema they produce. The database is
+// migrated up to until to dump its schema, so it must not have applied the
+// migrations coming after. The squashed files are moved to database/migrations/squashed.
+func MigrateSquash(currpath, driver, connStr, until string) {
+	if until == "" {
+		iziLogger.Log.Fatal("Set the last migration to squash with -until")
+	}
+	m := newMigrator(currpath, driver, connStr, true)
+	defer m.close()
+	if m.dryRun {
+		iziLogger.Log.Fatal("Squash does not support -dry-run")
+	}
+
+	i, target := m.find(until)
+	squashed, later := m.migrations[:i+1], m.migrations[i+1:]
+	records := m.records()
+	for _, f := range later {
+		if records[f.Name].applied() {
+			iziLogger.Log.Errorf("Migration '%s' coming after '%s' is applied", f.Name, target.Name)
+			iziLogger.Log.Hint("Squash from a database migrated up to '" + target.Name + "' at most, such as an empty scratch database")
+			m.close()
+			os.Exit(2)
+		}
+	}
+	m.upgrade(target.Name)
+
+	dump, err := dumpSchema(m.db, m.driver)
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not dump the database schema: %s", err)
+	}
+
+	// Move the squashed files away, a previous baseline among them included
+	timestamp := target.Created.Format(migrationDateFormat)
+	archive := path.Join(m.dir, squashedDir, timestamp)
+	if err := os.MkdirAll(archive, 0755); err != nil {
+		iziLogger.Log.Fatalf("Could not create directory: %s", err)
+	}
+	for _, f := range squashed {
+		for _, file := range []string{f.GoFile, f.UpFile, f.DownFile} {
+			if file == "" {
+				continue
+			}
+			if err := os.Rename(path.Join(m.dir, file), path.Join(archive, file)); err != nil {
+				iziLogger.Log.Fatalf("Could not move migration '%s': %s", file, err)
+			}
+		}
+	}
+
+	var header strings.Builder
+	fmt.Fprintf(&header, "-- Baseline of the migrations up to %s, generated by izi migrate squash.\n", target.Name)
+	header.WriteString("-- Databases which applied the squashed migrations record it as applied without running it.\n")
+	for _, f := range squashed {
+		fmt.Fprintf(&header, "-- izi:squashes %s\n", f.Name)
+	}
+	header.WriteString("\n")
+
+	baseline := &migrationFile{
+		Name:     "baseline_" + timestamp,
+		Created:  target.Created,
+		UpFile:   timestamp + "_baseline.up.sql",
+		DownFile: timestamp + "_baseline.down.sql",
+	}
+	down := header.String()
+	for _, statement := range dump.dropStatements() {
+		down += statement + ";\n"
+	}
+	writeBaselineFile(path.Join(m.dir, baseline.UpFile), header.String()+dump.String())
+	writeBaselineFile(path.Join(m.dir, baseline.DownFile), down)
+	iziLogger.Log.Infof("|> Squashed %d migrations into '%s'", len(squashed), baseline.UpFile)
+
+	// This database ran the squashed migrations
+	m.migrations = append([]*migrationFile{baseline}, later...)
+	m.squashed = loadSquashed(m.dir, m.migrations)
+	m.upgrade(baseline.Name)
+}
+
+func writeBaselineFile(file, content string) {
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		iziLogger.Log.Fatalf("Could not write the baseline: %s", err)
+	}
+}
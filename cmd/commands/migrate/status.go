@@ -0,0 +1,117 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	iziLogger "github.com/izi-global/izi/logger"
+)
+
+// MigrateStatus prints the state of every migration. With checkPending,
+// it returns a non-zero status when migrations are pending, with strict
+// when applied migrations were modified, deleted or renamed.
+func MigrateStatus(currpath, driver, connStr string, checkPending, strict bool) int {
+	m := newMigrator(currpath, driver, connStr, false)
+	defer m.close()
+
+	records := m.records()
+	drifts := m.drifts(records)
+	modified := make(map[string]bool)
+	for _, d := range drifts {
+		if d.file != nil {
+			modified[d.file.Name] = true
+		}
+	}
+
+	pending := 0
+	var failed []*migrationRecord
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "MIGRATION\tFILE\tSTATE\tUPDATED AT")
+	for _, f := range m.migrations {
+		r := records[f.Name]
+		state, updated := "pending", "-"
+		switch {
+		case r.applied() && r.Error != "":
+			state, updated = "applied, rollback failed", formatRecordTime(r.CreatedAt)
+			failed = append(failed, r)
+		case r.applied() && modified[f.Name]:
+			state, updated = "applied, modified", formatRecordTime(r.CreatedAt)
+		case r.applied():
+			state, updated = "applied", formatRecordTime(r.CreatedAt)
+		case r.failed():
+			state, updated = "failed", formatRecordTime(r.CreatedAt)
+			failed = append(failed, r)
+			pending++
+		case r != nil:
+			state, updated = "rolled back", formatRecordTime(r.CreatedAt)
+			pending++
+		default:
+			pending++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.Name, f.file(), state, updated)
+		delete(records, f.Name)
+	}
+
+	// Applied migrations whose file has been removed
+	var missing []*migrationRecord
+	for _, r := range records {
+		if r.applied() && m.squashed[r.Name] == "" {
+			missing = append(missing, r)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].ID < missing[j].ID })
+	for _, r := range missing {
+		fmt.Fprintf(w, "%s\t-\tapplied, file missing\t%s\n", r.Name, formatRecordTime(r.CreatedAt))
+	}
+	w.Flush()
+
+	for _, r := range failed {
+		iziLogger.Log.Errorf("Migration '%s' failed: %s", r.Name, r.Error)
+	}
+	for _, d := range drifts {
+		if strict {
+			iziLogger.Log.Error(d.String())
+		} else {
+			iziLogger.Log.Warn(d.String())
+		}
+	}
+	if strict && len(drifts) > 0 {
+		return 1
+	}
+
+	if pending == 0 {
+		iziLogger.Log.Success("The database is up-to-date")
+		return 0
+	}
+	if checkPending {
+		iziLogger.Log.Errorf("There are %d pending migrations", pending)
+		return 1
+	}
+	iziLogger.Log.Infof("There are %d pending migrations", pending)
+	return 0
+}
+
+// formatRecordTime formats the created_at column the same way for every driver.
+func formatRecordTime(value string) string {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t.Format("2006-01-02 15:04:05")
+	}
+	return value
+}
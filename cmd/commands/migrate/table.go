@@ -0,0 +1,240 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package migrate
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+
+	iziLogger "github.com/izi-global/izi/logger"
+	"github.com/izi-global/izi/utils"
+	"github.com/mattn/go-isatty"
+)
+
+// Creates the type of the status column unless it exists, the migrations
+// table may have been dropped without it
+const postgresStatusTypeDDL = `
+DO $$ BEGIN
+	CREATE TYPE migrations_status AS ENUM('update', 'rollback');
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+`
+
+// Columns missing from the migrations tables of older versions, added on upgrade
+var upgradableMigrationColumns = []string{"statements", "rollback_statements", "status", "error_message", "checksum"}
+
+// tableColumn describes a column of the migrations table.
+type tableColumn struct {
+	name          string
+	typ           string
+	nullable      bool
+	primary       bool
+	autoIncrement bool
+	def           string
+}
+
+// checkForSchemaUpdateTable creates the migrations table if it does not
+// exist. Otherwise it checks the structure of the table; the columns that
+// older versions did not create are added when upgrade is set, once
+// confirmed, and only reported otherwise. It returns false when the upgrade
+// is refused.
+func checkForSchemaUpdateTable(db *sql.DB, driver string, upgrade bool) bool {
+	columns, err := migrationsTableColumns(db, driver)
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not show columns of migrations table: %s", err)
+	}
+	if len(columns) == 0 {
+		iziLogger.Log.Infof("Creating 'migrations' table...")
+		if _, err := db.Exec(createMigrationsTableSQL(driver)); err != nil {
+			iziLogger.Log.Fatalf("Could not create migrations table: %s", err)
+		}
+		return true
+	}
+
+	validateMigrationsTable(columns)
+
+	var missing []string
+	for _, name := range upgradableMigrationColumns {
+		if _, ok := columns[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return true
+	}
+	if !upgrade {
+		iziLogger.Log.Warnf("The migrations table was created by an older version of izi, it lacks the columns %s", strings.Join(missing, ", "))
+		iziLogger.Log.Hint("Run 'izi migrate upgrade-table' to add them")
+		return true
+	}
+	if !mYes {
+		if !isatty.IsTerminal(os.Stdin.Fd()) {
+			iziLogger.Log.Hint("Run 'izi migrate upgrade-table', or pass -yes to upgrade it without asking")
+			iziLogger.Log.Errorf("The migrations table was created by an older version of izi, it lacks the columns %s", strings.Join(missing, ", "))
+			return false
+		}
+		iziLogger.Log.Warnf("The migrations table was created by an older version of izi. Do you want to add the columns %s? [Yes|No] ", strings.Join(missing, ", "))
+		if !utils.AskForConfirmation() {
+			iziLogger.Log.Error("The migrations table must be upgraded to run the migrations")
+			return false
+		}
+	}
+	upgradeMigrationsTable(db, driver, missing)
+	return true
+}
+
+// upgradeMigrationsTable adds the missing columns to the migrations table.
+func upgradeMigrationsTable(db *sql.DB, driver string, missing []string) {
+	for _, name := range missing {
+		iziLogger.Log.Infof("Upgrading 'migrations' table: adding column '%s'", name)
+		if driver == "postgres" && name == "status" {
+			if _, err := db.Exec(postgresStatusTypeDDL); err != nil {
+				iziLogger.Log.Fatalf("Could not create the migrations_status type: %s", err)
+			}
+		}
+		if _, err := db.Exec("ALTER TABLE migrations ADD COLUMN " + name + " " + migrationColumnDefinition(driver, name)); err != nil {
+			iziLogger.Log.Fatalf("Could not add the %s column to the migrations table: %s", name, err)
+		}
+		if name == "status" {
+			// The table recorded the applied migrations only
+			if _, err := db.Exec("UPDATE migrations SET status = 'update'"); err != nil {
+				iziLogger.Log.Fatalf("Could not upgrade the migrations table: %s", err)
+			}
+		}
+	}
+}
+
+// validateMigrationsTable checks the columns which cannot be upgraded.
+func validateMigrationsTable(columns map[string]*tableColumn) {
+	for _, name := range []string{"id_migration", "name", "created_at"} {
+		if _, ok := columns[name]; !ok {
+			iziLogger.Log.Hint("The migrations table was not created by izi, rename it or drop it")
+			iziLogger.Log.Fatalf("Column migrations.%s is missing", name)
+		}
+	}
+
+	id := columns["id_migration"]
+	if !id.primary || !id.autoIncrement {
+		iziLogger.Log.Hint("Expecting KEY: PRI, EXTRA: auto_increment")
+		iziLogger.Log.Fatalf("Column migrations.id_migration type mismatch: primary key: %t, auto increment: %t", id.primary, id.autoIncrement)
+	}
+
+	name := columns["name"]
+	typ := strings.ToLower(name.typ)
+	if !strings.Contains(typ, "char") && !strings.Contains(typ, "text") || !name.nullable {
+		iziLogger.Log.Hint("Expecting TYPE: varchar, NULL: YES")
+		iziLogger.Log.Fatalf("Column migrations.name type mismatch: TYPE: %s, NULL: %t", name.typ, name.nullable)
+	}
+
+	created := columns["created_at"]
+	typ = strings.ToLower(created.typ)
+	def := strings.ToUpper(strings.TrimSuffix(created.def, "()"))
+	if !strings.HasPrefix(typ, "timestamp") && typ != "datetime" || def != "CURRENT_TIMESTAMP" && def != "NOW" {
+		iziLogger.Log.Hint("Expecting TYPE: timestamp, DEFAULT: CURRENT_TIMESTAMP")
+		iziLogger.Log.Fatalf("Column migrations.created_at type mismatch: TYPE: %s, DEFAULT: %s", created.typ, created.def)
+	}
+}
+
+// migrationsTableColumns returns the columns of the migrations table of the
+// current database or schema, none when the table does not exist.
+func migrationsTableColumns(db *sql.DB, driver string) (map[string]*tableColumn, error) {
+	var query string
+	switch driver {
+	case "postgres":
+		query = `SELECT c.column_name, CASE WHEN c.data_type = 'USER-DEFINED' THEN c.udt_name ELSE c.data_type END,
+			c.is_nullable = 'YES', EXISTS (
+				SELECT 1 FROM information_schema.table_constraints t
+				JOIN information_schema.key_column_usage k ON k.constraint_schema = t.constraint_schema AND k.constraint_name = t.constraint_name
+				WHERE t.table_schema = c.table_schema AND t.table_name = c.table_name AND t.constraint_type = 'PRIMARY KEY' AND k.column_name = c.column_name
+			), c.is_identity = 'YES' OR COALESCE(c.column_default, '') LIKE 'nextval(%', COALESCE(c.column_default, '')
+			FROM information_schema.columns c WHERE c.table_schema = current_schema() AND c.table_name = 'migrations'`
+	case "sqlite":
+		// INTEGER PRIMARY KEY columns alias the rowid, which is auto incremented
+		query = `SELECT name, type, "notnull" = 0, pk > 0, pk > 0 AND upper(type) = 'INTEGER', COALESCE(dflt_value, '')
+			FROM pragma_table_info('migrations')`
+	default:
+		query = `SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE = 'YES', COLUMN_KEY = 'PRI', EXTRA LIKE '%auto_increment%', COALESCE(COLUMN_DEFAULT, '')
+			FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'migrations'`
+	}
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns := make(map[string]*tableColumn)
+	for rows.Next() {
+		c := &tableColumn{}
+		if err := rows.Scan(&c.name, &c.typ, &c.nullable, &c.primary, &c.autoIncrement, &c.def); err != nil {
+			return nil, err
+		}
+		columns[c.name] = c
+	}
+	return columns, rows.Err()
+}
+
+// migrationColumnDefinition returns the type of a column added by an upgrade.
+func migrationColumnDefinition(driver, column string) string {
+	switch column {
+	case "statements", "rollback_statements":
+		if driver == "mysql" {
+			return "longtext"
+		}
+		return "text"
+	case "status":
+		switch driver {
+		case "postgres":
+			return "migrations_status"
+		case "sqlite":
+			return "varchar(8) CHECK (status IN ('update', 'rollback'))"
+		}
+		return "ENUM('update', 'rollback')"
+	case "checksum":
+		return "varchar(64)"
+	}
+	return "text"
+}
+
+// migrationColumnExists reports whether the migrations table has the column.
+func migrationColumnExists(db *sql.DB, column string) bool {
+	rows, err := db.Query("SELECT " + column + " FROM migrations WHERE 1 = 0")
+	if err != nil {
+		return false
+	}
+	rows.Close()
+	return true
+}
+
+// optionalColumn selects the column, or NULL when the migrations table predates it.
+func optionalColumn(db *sql.DB, column string) string {
+	if migrationColumnExists(db, column) {
+		return column
+	}
+	return "NULL"
+}
+
+func createMigrationsTableSQL(driver string) string {
+	switch driver {
+	case "mysql":
+		return MYSQLMigrationDDL
+	case "postgres":
+		return POSTGRESMigrationDDL
+	case "sqlite":
+		return SQLITEMigrationDDL
+	default:
+		return MYSQLMigrationDDL
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package run
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	path "path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/izi-global/izi/config"
+	iziLogger "github.com/izi-global/izi/logger"
+	"github.com/izi-global/izi/utils"
+)
+
+// versionInfo is the data available to the -ldflags template
+type versionInfo struct {
+	Version string
+	Commit  string
+	Date    string
+}
+
+// buildFlags returns the extra "go build" arguments from the command line
+// flags and the configuration, the flags taking precedence.
+func buildFlags() []string {
+	b := config.Conf.Build
+	var args []string
+
+	if raceBuild || b.Race {
+		args = append(args, "-race")
+	}
+	if coverEnabled() {
+		args = append(args, "-cover")
+	}
+	if trimpath || b.Trimpath {
+		args = append(args, "-trimpath")
+	}
+
+	flags := ldflags
+	if flags == "" {
+		flags = b.Ldflags
+	}
+	if flags != "" {
+		args = append(args, "-ldflags", expandVersion(flags))
+	}
+
+	flags = gcflags
+	if flags == "" {
+		flags = b.Gcflags
+	}
+	if flags != "" {
+		args = append(args, "-gcflags", flags)
+	}
+
+	args = append(args, config.Conf.BuildArgs...)
+	if buildArgs != "" {
+		args = append(args, utils.SplitQuotedFields(buildArgs)...)
+	}
+	return args
+}
+
+// expandVersion replaces the version placeholders of the linker flags,
+// e.g. "-X main.version={{.Version}}".
+func expandVersion(flags string) string {
+	if !strings.Contains(flags, "{{") {
+		return flags
+	}
+
+	tmpl, err := template.New("ldflags").Parse(flags)
+	if err != nil {
+		iziLogger.Log.Warnf("Invalid ldflags template: %s", err)
+		return flags
+	}
+
+	info := versionInfo{
+		Version: gitOutput("describe", "--tags", "--always", "--dirty"),
+		Commit:  gitOutput("rev-parse", "--short", "HEAD"),
+		Date:    time.Now().UTC().Format(time.RFC3339),
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, info); err != nil {
+		iziLogger.Log.Warnf("Invalid ldflags template: %s", err)
+		return flags
+	}
+	return buf.String()
+}
+
+func gitOutput(args ...string) string {
+	c := exec.Command("git", args...)
+	c.Dir = currpath
+	out, err := c.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// coverEnabled reports whether the application is built with coverage.
+func coverEnabled() bool {
+	return coverBuild || config.Conf.Build.Cover
+}
+
+// coverDir returns the absolute GOCOVERDIR of the application.
+func coverDir() string {
+	dir := config.Conf.Build.CoverDir
+	if dir == "" {
+		dir = ".cover"
+	}
+	if !path.IsAbs(dir) {
+		dir = path.Join(currpath, dir)
+	}
+	return dir
+}
+
+// binaryDir returns the directory where the application binary is written:
+// the application path, or the user cache directory.
+func binaryDir() string {
+	if !binCache && !config.Conf.Build.BinCache {
+		return currpath
+	}
+
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		iziLogger.Log.Warnf("Cannot find the cache directory, using the application path: %s", err)
+		return currpath
+	}
+	dir := path.Join(cache, "izi", appname)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		iziLogger.Log.Warnf("Cannot create '%s', using the application path: %s", dir, err)
+		return currpath
+	}
+	return dir
+}
+
+// reportCoverage prints the coverage collected while the application ran.
+func reportCoverage() {
+	if !coverEnabled() {
+		return
+	}
+	dir := coverDir()
+	iziLogger.Log.Infof("Coverage data collected in '%s'", dir)
+
+	c := exec.Command("go", "tool", "covdata", "percent", "-i="+dir)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		iziLogger.Log.Warnf("Could not read the coverage data: %s", err)
+	}
+}
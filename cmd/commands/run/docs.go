@@ -2,55 +2,158 @@ package run
 
 import (
 	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
+	path "path/filepath"
 	"strings"
+	"time"
 
+	"github.com/izi-global/izi/config"
+	"github.com/izi-global/izi/generate/swaggergen"
+	"github.com/izi-global/izi/generate/swaggerui"
 	iziLogger "github.com/izi-global/izi/logger"
+	"github.com/izi-global/izi/utils"
 )
 
-var (
-	swaggerVersion = "3"
-	swaggerlink    = "https://github.com/izi-global/swagger/archive/v" + swaggerVersion + ".zip"
-)
+// generateDocs generates swagger.json and swagger.yml in the application
+// "swagger" folder. The errors of the annotations are reported without
+// stopping the watcher and the application.
+func generateDocs() {
+	defer func() {
+		if e := recover(); e != nil {
+			iziLogger.Log.Errorf("Failed to generate the docs: %s", e)
+		}
+	}()
+	iziLogger.Log.Info("Generating the docs...")
+	swaggergen.ResetCache()
+	swaggergen.ParsePackagesFromDir(currpath)
+	if err := swaggergen.GenerateDocs(currpath); err != nil {
+		utils.Notify("", "Failed to generate the docs.")
+		iziLogger.Log.Errorf("Failed to generate the docs: %s", err)
+		return
+	}
+	iziLogger.Log.Success("Docs generated!")
+}
+
+// isDocsSource reports whether the file is part of the controllers or the
+// routers, the only packages whose changes require generating the docs again.
+func isDocsSource(filename string) bool {
+	rel, err := path.Rel(currpath, filename)
+	if err != nil {
+		return false
+	}
+	dir := strings.SplitN(path.ToSlash(rel), "/", 2)[0]
+	return dir == config.Conf.DirStruct.Controllers || dir == "routers"
+}
 
-func downloadFromURL(url, fileName string) {
-	var down bool
-	if fd, err := os.Stat(fileName); err != nil && os.IsNotExist(err) {
-		down = true
-	} else if fd.Size() == int64(0) {
-		down = true
-	} else {
-		iziLogger.Log.Infof("'%s' already exists", fileName)
+// serveDocs serves the Swagger UI and the generated docs under /swagger/.
+// The bundled UI is used when available, the application one otherwise.
+func serveDocs(w http.ResponseWriter, r *http.Request) {
+	docsDir := path.Join(currpath, "swagger")
+	name := strings.TrimPrefix(r.URL.Path, "/swagger/")
+	if name == "swagger.json" || name == "swagger.yml" {
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeFile(w, r, path.Join(docsDir, name))
 		return
 	}
-	if down {
-		iziLogger.Log.Infof("Downloading '%s' to '%s'...", url, fileName)
-		output, err := os.Create(fileName)
-		if err != nil {
-			iziLogger.Log.Errorf("Error while creating '%s': %s", fileName, err)
-			return
-		}
-		defer output.Close()
 
-		response, err := http.Get(url)
+	ui, err := swaggerui.FS()
+	if err != nil {
+		ui = os.DirFS(docsDir)
+	}
+	if name == "" || name == "index.html" {
+		index, err := fs.ReadFile(ui, "index.html")
 		if err != nil {
-			iziLogger.Log.Errorf("Error while downloading '%s': %s", url, err)
+			http.Error(w, "Swagger UI not found, run 'izi docs ui install'", http.StatusNotFound)
 			return
 		}
-		defer response.Body.Close()
+		// Reload the page when the docs are generated again
+		index = insertBeforeBodyEnd(index, []byte(`<script src="/livereload.js"></script>`))
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(index)
+		return
+	}
+	http.StripPrefix("/swagger/", http.FileServer(http.FS(ui))).ServeHTTP(w, r)
+}
 
-		n, err := io.Copy(output, response.Body)
-		if err != nil {
-			iziLogger.Log.Errorf("Error while downloading '%s': %s", url, err)
+// installSwagger installs the Swagger UI into the application "swagger"
+// folder: the distribution bundled in izi, or an archive downloaded from
+// "swagger.url" when configured.
+func installSwagger() {
+	dir := path.Join(currpath, "swagger")
+	conf := config.Conf.Swagger
+	if conf.URL == "" {
+		iziLogger.Log.Infof("Installing the bundled Swagger UI %s...", swaggerui.Version)
+		if err := swaggerui.Install(dir); err != nil {
+			iziLogger.Log.Errorf("Could not install the Swagger UI: %s", err)
 			return
 		}
-		iziLogger.Log.Successf("%d bytes downloaded!", n)
+		iziLogger.Log.Success("Swagger UI installed!")
+		return
+	}
+
+	if conf.SHA256 == "" {
+		iziLogger.Log.Error("Refusing to download the Swagger UI without its checksum, set 'swagger.sha256' in IZIfile")
+		return
+	}
+	archive := path.Join(currpath, "swagger.zip")
+	if err := downloadFromURL(conf.URL, archive, conf.SHA256); err != nil {
+		iziLogger.Log.Errorf("Error while downloading '%s': %s", conf.URL, err)
+		os.Remove(archive)
+		return
+	}
+	if err := unzipAndDelete(archive, dir); err != nil {
+		iziLogger.Log.Errorf("Error while unzipping '%s': %s", archive, err)
+	}
+}
+
+// downloadFromURL downloads the file and verifies its SHA256 checksum.
+// The proxy is taken from the HTTP_PROXY/HTTPS_PROXY environment variables.
+func downloadFromURL(url, fileName, checksum string) error {
+	timeout := parseDuration(config.Conf.Swagger.Timeout, time.Minute)
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment},
+	}
+
+	iziLogger.Log.Infof("Downloading '%s' to '%s'...", url, fileName)
+	response, err := client.Get(url)
+	if err != nil {
+		return err
 	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status '%s'", response.Status)
+	}
+
+	output, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(output, hash), response.Body)
+	if err != nil {
+		return err
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if !strings.EqualFold(sum, checksum) {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", checksum, sum)
+	}
+	iziLogger.Log.Successf("%d bytes downloaded and verified!", n)
+	return nil
 }
 
-func unzipAndDelete(src string) error {
+// unzipAndDelete extracts the archive into dir, dropping the top-level
+// folder of the entries, and deletes it. Entries escaping dir are rejected.
+func unzipAndDelete(src, dir string) error {
 	iziLogger.Log.Infof("Unzipping '%s'...", src)
 	r, err := zip.OpenReader(src)
 	if err != nil {
@@ -58,31 +161,49 @@ func unzipAndDelete(src string) error {
 	}
 	defer r.Close()
 
-	rp := strings.NewReplacer("swagger-"+swaggerVersion, "swagger")
 	for _, f := range r.File {
-		rc, err := f.Open()
+		name := f.Name
+		if i := strings.Index(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+		if name == "" {
+			continue
+		}
+
+		fname, err := utils.SecureJoin(dir, name)
 		if err != nil {
 			return err
 		}
-		defer rc.Close()
-
-		fname := rp.Replace(f.Name)
 		if f.FileInfo().IsDir() {
-			os.MkdirAll(fname, f.Mode())
-		} else {
-			f, err := os.OpenFile(
-				fname, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-
-			_, err = io.Copy(f, rc)
-			if err != nil {
+			if err := os.MkdirAll(fname, 0755); err != nil {
 				return err
 			}
+			continue
+		}
+		if err := extractFile(f, fname); err != nil {
+			return err
 		}
 	}
 	iziLogger.Log.Successf("Done! Deleting '%s'...", src)
 	return os.RemoveAll(src)
 }
+
+func extractFile(f *zip.File, fname string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if err := os.MkdirAll(path.Dir(fname), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, rc)
+	return err
+}
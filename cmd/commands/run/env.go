@@ -0,0 +1,86 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package run
+
+import (
+	"os"
+	path "path/filepath"
+	"strings"
+
+	"github.com/izi-global/izi/config"
+	iziLogger "github.com/izi-global/izi/logger"
+	"github.com/izi-global/izi/utils"
+)
+
+// envFilePaths returns the absolute paths of the dotenv files loaded into
+// the application environment, -env-file overriding "env_files".
+func envFilePaths() []string {
+	files := config.Conf.EnvFiles
+	if len(envFiles) > 0 {
+		files = envFiles
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		if !path.IsAbs(f) {
+			f = path.Join(currpath, f)
+		}
+		paths = append(paths, f)
+	}
+	return paths
+}
+
+// isEnvFile reports whether the file is one of the loaded dotenv files.
+func isEnvFile(filename string) bool {
+	abs, err := path.Abs(filename)
+	if err != nil {
+		return false
+	}
+	for _, f := range envFilePaths() {
+		if f == abs {
+			return true
+		}
+	}
+	return false
+}
+
+// loadEnvFiles returns the variables defined in the dotenv files as KEY=value
+// pairs. Later files override earlier ones and missing files are skipped.
+func loadEnvFiles() []string {
+	env := make(map[string]string)
+	for _, e := range config.Conf.Envs {
+		if kv := strings.SplitN(e, "=", 2); len(kv) == 2 {
+			env[kv[0]] = kv[1]
+		}
+	}
+
+	var pairs []string
+	for _, f := range envFilePaths() {
+		vars, err := utils.ParseDotenvFile(f, env)
+		if err != nil {
+			if !os.IsNotExist(err) || len(envFiles) > 0 {
+				iziLogger.Log.Errorf("Failed to load the environment file: %s", err)
+			}
+			continue
+		}
+		for _, v := range vars {
+			kv := strings.SplitN(v, "=", 2)
+			env[kv[0]] = kv[1]
+		}
+		iziLogger.Log.Debugf("Loaded %d variables from '%s'", utils.FILE(), utils.LINE(), len(vars), f)
+		pairs = append(pairs, vars...)
+	}
+	return pairs
+}
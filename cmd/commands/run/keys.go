@@ -0,0 +1,201 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package run
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/izi-global/izi/config"
+	iziLogger "github.com/izi-global/izi/logger"
+	"github.com/izi-global/izi/logger/colors"
+	"github.com/izi-global/izi/utils"
+	"github.com/mattn/go-isatty"
+	"github.com/peterh/liner"
+)
+
+var (
+	// Set while watching is paused with the 'p' key
+	watchPaused int32
+	// Application output, muted with the 'l' key
+	appStdout = &switchWriter{w: os.Stdout}
+	appStderr = &switchWriter{w: os.Stderr}
+	// Terminal state restored when izi quits
+	term     *liner.State
+	termOnce sync.Once
+)
+
+var keysHelp = `Keyboard controls:
+  r  rebuild and restart      R  restart without building
+  t  run the tests            c  clear the screen
+  l  toggle application logs  p  pause/resume watching
+  q  quit`
+
+// switchWriter is an io.Writer that can be muted.
+type switchWriter struct {
+	w     io.Writer
+	muted int32
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	if atomic.LoadInt32(&s.muted) == 1 {
+		return len(p), nil
+	}
+	return s.w.Write(p)
+}
+
+// toggle mutes the writer if it is not muted and unmutes it otherwise.
+// It returns true if the writer is now muted.
+func (s *switchWriter) toggle() bool {
+	for {
+		old := atomic.LoadInt32(&s.muted)
+		if atomic.CompareAndSwapInt32(&s.muted, old, 1-old) {
+			return old == 0
+		}
+	}
+}
+
+// isWatchPaused reports whether file events should be ignored.
+func isWatchPaused() bool {
+	return atomic.LoadInt32(&watchPaused) == 1
+}
+
+// startKeyboardControls reads single key presses from the terminal and runs
+// the matching action. It does nothing when stdin is not a terminal.
+func startKeyboardControls(files []string, isgenerate bool) {
+	if !isatty.IsTerminal(os.Stdin.Fd()) || !liner.TerminalSupported() {
+		iziLogger.Log.Debugf("Stdin is not a terminal, keyboard controls are disabled", utils.FILE(), utils.LINE())
+		return
+	}
+
+	// NewLiner puts the terminal into raw mode, key presses are then
+	// read one by one from stdin without waiting for a new line.
+	term = liner.NewLiner()
+	iziLogger.Log.Hint(keysHelp)
+
+	// Restore the terminal when izi is interrupted
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		quit()
+	}()
+
+	go func() {
+		r := bufio.NewReader(os.Stdin)
+		for {
+			key, _, err := r.ReadRune()
+			if err != nil {
+				restoreTerminal()
+				return
+			}
+			handleKey(key, files, isgenerate)
+		}
+	}()
+}
+
+// handleKey runs the action bound to the key.
+func handleKey(key rune, files []string, isgenerate bool) {
+	switch key {
+	case 'r':
+		iziLogger.Log.Info("Rebuilding...")
+		go func() {
+			AutoBuild(files, isgenerate)
+			if config.Conf.EnableReload {
+				sendReload("")
+			}
+		}()
+	case 'R':
+		go func() {
+			restartApp()
+			if config.Conf.EnableReload {
+				sendReload("")
+			}
+		}()
+	case 't':
+		go runTests()
+	case 'c':
+		clearScreen()
+	case 'l':
+		muted := appStdout.toggle()
+		appStderr.toggle()
+		if muted {
+			iziLogger.Log.Info("Application logs hidden")
+		} else {
+			iziLogger.Log.Info("Application logs shown")
+		}
+	case 'p':
+		if atomic.CompareAndSwapInt32(&watchPaused, 0, 1) {
+			iziLogger.Log.Info("Watching paused, press 'p' to resume")
+		} else {
+			atomic.StoreInt32(&watchPaused, 0)
+			iziLogger.Log.Info("Watching resumed")
+		}
+	case 'q':
+		quit()
+	case 'h', '?':
+		iziLogger.Log.Hint(keysHelp)
+	}
+}
+
+// runTests runs "go test" on the application packages.
+func runTests() {
+	iziLogger.Log.Info("Running the tests...")
+	c := exec.Command("go", "test", "./...")
+	c.Dir = currpath
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		iziLogger.Log.Errorf("Tests failed: %s", err)
+		return
+	}
+	iziLogger.Log.Success("Tests passed!")
+}
+
+func clearScreen() {
+	if runtime.GOOS == "windows" {
+		c := exec.Command("cmd", "/C", "cls")
+		c.Stdout = os.Stdout
+		c.Run()
+		return
+	}
+	fmt.Fprint(os.Stdout, "\033[H\033[2J")
+}
+
+// quit stops the application, restores the terminal and exits.
+func quit() {
+	iziLogger.Log.Info(colors.Bold("Shutting down..."))
+	state.Lock()
+	Kill()
+	reportCoverage()
+	restoreTerminal()
+	os.Exit(0)
+}
+
+func restoreTerminal() {
+	termOnce.Do(func() {
+		if term != nil {
+			term.Close()
+		}
+	})
+}
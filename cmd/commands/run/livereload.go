@@ -0,0 +1,180 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package run
+
+import (
+	"encoding/json"
+	"net/http"
+
+	iziLogger "github.com/izi-global/izi/logger"
+)
+
+// The LiveReload protocol spoken by the /livereload endpoint,
+// see http://livereload.com/api/protocol/
+const liveReloadProtocol = "http://livereload.com/protocols/official-7"
+
+// Files swapped in place by LiveReload clients when liveImg is set
+var imageExts = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+	".svg":  true,
+	".webp": true,
+	".ico":  true,
+}
+
+// reloadCommand is the LiveReload "reload" command sent to the browsers.
+type reloadCommand struct {
+	Command string `json:"command"`
+	Path    string `json:"path"`
+	LiveCSS bool   `json:"liveCSS"`
+	LiveImg bool   `json:"liveImg"`
+}
+
+// helloReply answers the hello of the clients.
+var helloReply, _ = json.Marshal(helloCommand{
+	Command:    "hello",
+	Protocols:  []string{liveReloadProtocol},
+	ServerName: "izi",
+})
+
+// helloCommand is the LiveReload handshake message.
+type helloCommand struct {
+	Command    string   `json:"command"`
+	Protocols  []string `json:"protocols"`
+	ServerName string   `json:"serverName,omitempty"`
+}
+
+// handleCommand handles a LiveReload command sent by the client.
+func (c *wsClient) handleCommand(message []byte) {
+	var hello helloCommand
+	if err := json.Unmarshal(message, &hello); err != nil {
+		iziLogger.Log.Warnf("Invalid LiveReload message: %s", message)
+		return
+	}
+	// Other commands, such as "info", are only informational
+	if hello.Command != "hello" {
+		return
+	}
+
+	supported := false
+	for _, p := range hello.Protocols {
+		if p == liveReloadProtocol {
+			supported = true
+		}
+	}
+	if !supported {
+		iziLogger.Log.Warnf("LiveReload client does not support '%s'", liveReloadProtocol)
+		c.conn.Close()
+		return
+	}
+
+	// The broker sends the reply, it may have closed the send channel
+	c.broker.hello <- c
+}
+
+func serveLiveReloadJS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(liveReloadJS))
+}
+
+// liveReloadJS is a LiveReload client connecting back to the server it was
+// loaded from. Stylesheets and images are swapped in place, any other change
+// reloads the page.
+const liveReloadJS = `(function () {
+  'use strict';
+  var script = document.currentScript ||
+    document.querySelector('script[src*="livereload.js"]');
+  var src = script ? new URL(script.src, location.href) : null;
+  var host = src ? src.host : location.hostname + ':35729';
+  var scheme = (src ? src.protocol : location.protocol) === 'https:' ? 'wss://' : 'ws://';
+  var protocol = '` + liveReloadProtocol + `';
+
+  function basename(url) {
+    return url.split('#')[0].split('?')[0].split('/').pop();
+  }
+
+  function bust(url) {
+    var clean = url.replace(/([?&])livereload=\d+&?/, '$1').replace(/[?&]$/, '');
+    return clean + (clean.indexOf('?') < 0 ? '?' : '&') + 'livereload=' + Date.now();
+  }
+
+  function reloadCSS(name) {
+    var links = document.querySelectorAll('link[rel~="stylesheet"]');
+    var found = false;
+    Array.prototype.forEach.call(links, function (link) {
+      if (!link.href || basename(link.href) !== name) {
+        return;
+      }
+      found = true;
+      var clone = link.cloneNode(false);
+      clone.href = bust(link.href);
+      clone.onload = function () {
+        if (link.parentNode) {
+          link.parentNode.removeChild(link);
+        }
+      };
+      link.parentNode.insertBefore(clone, link.nextSibling);
+    });
+    return found;
+  }
+
+  function reloadImages(name) {
+    var found = false;
+    Array.prototype.forEach.call(document.images, function (img) {
+      if (basename(img.src) === name) {
+        found = true;
+        img.src = bust(img.src);
+      }
+    });
+    return found;
+  }
+
+  function reload(cmd) {
+    var name = basename(cmd.path || '');
+    if (name && cmd.liveCSS && /\.css$/i.test(name) && reloadCSS(name)) {
+      return;
+    }
+    if (name && cmd.liveImg && reloadImages(name)) {
+      return;
+    }
+    location.reload();
+  }
+
+  function connect() {
+    var ws = new WebSocket(scheme + host + '/livereload');
+    ws.onopen = function () {
+      ws.send(JSON.stringify({command: 'hello', protocols: [protocol]}));
+    };
+    ws.onmessage = function (event) {
+      var cmd = JSON.parse(event.data);
+      if (cmd.command === 'hello') {
+        ws.send(JSON.stringify({command: 'info', url: location.href, plugins: {}}));
+      } else if (cmd.command === 'reload') {
+        reload(cmd);
+      } else if (cmd.command === 'alert') {
+        alert(cmd.message);
+      }
+    };
+    ws.onclose = function () {
+      setTimeout(connect, 1000);
+    };
+  }
+
+  connect();
+})();
+`
@@ -0,0 +1,159 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package run
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/izi-global/izi/config"
+	iziLogger "github.com/izi-global/izi/logger"
+)
+
+// Page served by the proxy while the application is not reachable
+const proxyErrorPage = `<!DOCTYPE html>
+<html><head><title>izi</title></head>
+<body><h3>The application is not running yet</h3><p>%s</p></body></html>`
+
+// startProxy starts the development proxy in front of the application.
+// It does nothing if no proxy address is configured.
+func startProxy() {
+	p := config.Conf.Proxy
+	if proxyAddress != "" {
+		p.Address = proxyAddress
+	}
+	if p.Address == "" {
+		return
+	}
+
+	target := p.Target
+	if target == "" {
+		target = config.Conf.Readiness.Address
+	}
+	if target == "" {
+		iziLogger.Log.Fatal("The proxy needs the application address, set proxy.target or readiness.address")
+	}
+	if strings.HasPrefix(target, ":") {
+		target = "localhost" + target
+	}
+
+	rp := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: target})
+	inject := p.Inject && config.Conf.EnableReload
+	if inject {
+		rp.ModifyResponse = injectReloadScript
+	}
+	rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		page := []byte(fmt.Sprintf(proxyErrorPage, err))
+		if inject {
+			page = insertBeforeBodyEnd(page, reloadScriptTag(r))
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write(page)
+	}
+
+	go func() {
+		if err := http.ListenAndServe(p.Address, rp); err != nil {
+			iziLogger.Log.Errorf("Failed to start up the proxy: %v", err)
+		}
+	}()
+	iziLogger.Log.Infof("Proxy listening at %s, forwarding to %s", p.Address, target)
+}
+
+// injectReloadScript adds the reload client to the HTML documents. Responses
+// without a body are left untouched.
+func injectReloadScript(resp *http.Response) error {
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified ||
+		resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return nil
+	}
+
+	encoding := strings.ToLower(resp.Header.Get("Content-Encoding"))
+	if encoding != "" && encoding != "identity" && encoding != "gzip" {
+		// The body cannot be rewritten, leave it untouched
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	if encoding == "gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		body, err = ioutil.ReadAll(zr)
+		if err != nil {
+			return err
+		}
+		resp.Header.Del("Content-Encoding")
+	}
+
+	body = insertBeforeBodyEnd(body, reloadScriptTag(resp.Request))
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// reloadScriptTag returns the script tag loading the LiveReload client from
+// the reload server, on the host the browser used to reach the proxy.
+func reloadScriptTag(r *http.Request) []byte {
+	host := "localhost"
+	if r != nil && r.Host != "" {
+		host = r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+	}
+	address := config.Conf.Reload.LiveReloadAddress
+	if address == "" {
+		address = config.Conf.Reload.Address
+	}
+	_, port, _ := net.SplitHostPort(address)
+	return []byte(`<script src="//` + net.JoinHostPort(host, port) + `/livereload.js"></script>`)
+}
+
+// insertBeforeBodyEnd inserts the tag before the last </body>, or </html>.
+// Fragments of pages, such as the partials of htmx, are returned unchanged.
+func insertBeforeBodyEnd(page, tag []byte) []byte {
+	lower := bytes.ToLower(page)
+	i := bytes.LastIndex(lower, []byte("</body>"))
+	if i < 0 {
+		i = bytes.LastIndex(lower, []byte("</html>"))
+	}
+	if i < 0 {
+		return page
+	}
+
+	out := make([]byte, 0, len(page)+len(tag))
+	out = append(out, page[:i]...)
+	out = append(out, tag...)
+	return append(out, page[i:]...)
+}
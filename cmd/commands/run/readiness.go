@@ -0,0 +1,95 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package run
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/izi-global/izi/config"
+)
+
+// Delay between two readiness probes
+const probeInterval = 100 * time.Millisecond
+
+var errAppExited = errors.New("application exited before becoming ready")
+
+// readinessEnabled reports whether a readiness probe is configured.
+func readinessEnabled() bool {
+	return config.Conf.Readiness.Address != ""
+}
+
+// waitReady probes the application until it is ready, the configured timeout
+// expires or the process exits (signaled by closing done).
+func waitReady(done <-chan struct{}) error {
+	timeout := parseDuration(config.Conf.Readiness.Timeout, 30*time.Second)
+	deadline := time.Now().Add(timeout)
+
+	var err error
+	for {
+		if err = probe(); err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not ready after %s: %s", timeout, err)
+		}
+		select {
+		case <-done:
+			return errAppExited
+		case <-time.After(probeInterval):
+		}
+	}
+}
+
+// probe runs a single readiness check against the application.
+func probe() error {
+	r := config.Conf.Readiness
+	if r.Path == "" {
+		conn, err := net.DialTimeout("tcp", r.Address, time.Second)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	addr := r.Address
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	path := r.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	client := &http.Client{Timeout: time.Second}
+	resp, err := client.Get("http://" + addr + path)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	expected := r.Status
+	if expected == 0 {
+		expected = http.StatusOK
+	}
+	if resp.StatusCode != expected {
+		return fmt.Errorf("'%s' returned status %d, expected %d", path, resp.StatusCode, expected)
+	}
+	return nil
+}
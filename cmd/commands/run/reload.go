@@ -14,20 +14,25 @@
 package run
 
 import (
-	"bytes"
+	"encoding/json"
+	"net"
 	"net/http"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/izi-global/izi/config"
 	iziLogger "github.com/izi-global/izi/logger"
 )
 
 // wsBroker maintains the set of active clients and broadcasts messages to the clients.
 type wsBroker struct {
-	clients    map[*wsClient]bool // Registered clients.
-	broadcast  chan []byte        // Inbound messages from the clients.
-	register   chan *wsClient     // Register requests from the clients.
-	unregister chan *wsClient     // Unregister requests from clients.
+	clients    map[*wsClient]bool  // Registered clients.
+	broadcast  chan *reloadCommand // Reload commands sent to the clients.
+	register   chan *wsClient      // Register requests from the clients.
+	unregister chan *wsClient      // Unregister requests from clients.
+	hello      chan *wsClient      // LiveReload clients whose handshake is answered.
 }
 
 func (br *wsBroker) run() {
@@ -40,24 +45,56 @@ func (br *wsBroker) run() {
 				delete(br.clients, client)
 				close(client.send)
 			}
-		case message := <-br.broadcast:
+		case client := <-br.hello:
+			if _, ok := br.clients[client]; ok {
+				br.send(client, helloReply)
+				client.handshaked = true
+			}
+		case command := <-br.broadcast:
 			for client := range br.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(br.clients, client)
+				if message := client.encode(command); message != nil {
+					br.send(client, message)
 				}
 			}
 		}
 	}
 }
 
+// send queues the message of the client. The broker owns the send channels,
+// a client whose buffer is full is dropped.
+func (br *wsBroker) send(client *wsClient, message []byte) {
+	select {
+	case client.send <- message:
+	default:
+		close(client.send)
+		delete(br.clients, client)
+	}
+}
+
 // wsClient represents the end-client.
 type wsClient struct {
-	broker *wsBroker       // The broker.
-	conn   *websocket.Conn // The websocket connection.
-	send   chan []byte     // Buffered channel of outbound messages.
+	broker     *wsBroker       // The broker.
+	conn       *websocket.Conn // The websocket connection.
+	send       chan []byte     // Buffered channel of outbound messages.
+	livereload bool            // The client speaks the LiveReload protocol.
+	handshaked bool            // Set by the broker once the LiveReload hello is answered.
+}
+
+// encode returns the message sent to the client for the reload command,
+// or nil if the client must not receive it yet.
+func (c *wsClient) encode(command *reloadCommand) []byte {
+	if !c.livereload {
+		// Legacy clients of /reload receive the changed file name
+		return []byte(command.Path)
+	}
+	if !c.handshaked {
+		return nil
+	}
+	message, err := json.Marshal(command)
+	if err != nil {
+		return nil
+	}
+	return message
 }
 
 // readPump pumps messages from the websocket connection to the broker.
@@ -68,13 +105,16 @@ func (c *wsClient) readPump() {
 	}()
 
 	for {
-		_, _, err := c.conn.ReadMessage()
+		_, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway) {
 				iziLogger.Log.Errorf("An error happened when reading from the Websocket client: %v", err)
 			}
 			break
 		}
+		if c.livereload {
+			c.handleCommand(message)
+		}
 	}
 }
 
@@ -108,12 +148,6 @@ func (c *wsClient) writePump() {
 			}
 			w.Write(message)
 
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte("/n"))
-				w.Write(<-c.send)
-			}
-
 			if err := w.Close(); err != nil {
 				return
 			}
@@ -126,8 +160,7 @@ func (c *wsClient) writePump() {
 }
 
 var (
-	broker        *wsBroker  // The broker.
-	reloadAddress = ":12450" // The port on which the reload server will listen to.
+	broker *wsBroker // The broker.
 
 	upgrader = websocket.Upgrader{
 		ReadBufferSize:  1024,
@@ -136,6 +169,9 @@ var (
 	}
 )
 
+// Routes of the reload server which reload.path cannot take
+var reloadRoutes = []string{"/", "/status", "/events", "/livereload", "/livereload.js", "/swagger/"}
+
 const (
 	writeWait  = 10 * time.Second    // Time allowed to write a message to the peer.
 	pongWait   = 60 * time.Second    // Time allowed to read the next pong message from the peer.
@@ -144,36 +180,85 @@ const (
 
 func startReloadServer() {
 	broker = &wsBroker{
-		broadcast:  make(chan []byte),
+		broadcast:  make(chan *reloadCommand),
 		register:   make(chan *wsClient),
 		unregister: make(chan *wsClient),
+		hello:      make(chan *wsClient),
 		clients:    make(map[*wsClient]bool),
 	}
 
 	go broker.run()
-	http.HandleFunc("/reload", func(w http.ResponseWriter, r *http.Request) {
-		handleWsRequest(broker, w, r)
+
+	conf := config.Conf.Reload
+	if conf.Path == "" {
+		conf.Path = "/reload"
+	}
+	if !strings.HasPrefix(conf.Path, "/") {
+		iziLogger.Log.Fatalf("Invalid 'reload.path' in IZIfile: '%s' must start with '/'", conf.Path)
+	}
+	for _, route := range reloadRoutes {
+		if conf.Path == route {
+			iziLogger.Log.Fatalf("Invalid 'reload.path' in IZIfile: '%s' is already served by the Reload server", conf.Path)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(conf.Path, func(w http.ResponseWriter, r *http.Request) {
+		handleWsRequest(broker, w, r, false)
+	})
+	mux.HandleFunc("/livereload", func(w http.ResponseWriter, r *http.Request) {
+		handleWsRequest(broker, w, r, true)
 	})
+	mux.HandleFunc("/livereload.js", serveLiveReloadJS)
+	mux.HandleFunc("/status", serveStatus)
+	mux.HandleFunc("/events", serveEvents)
+	mux.HandleFunc("/swagger/", serveDocs)
+	mux.HandleFunc("/", serveDashboard)
 
-	go startServer()
-	iziLogger.Log.Infof("Reload server listening at %s", reloadAddress)
+	startServer(conf.Address, "reload.address", mux)
+	if conf.LiveReloadAddress != "" && conf.LiveReloadAddress != conf.Address {
+		startServer(conf.LiveReloadAddress, "reload.livereload_address", mux)
+	}
 }
 
-func startServer() {
-	err := http.ListenAndServe(reloadAddress, nil)
+// startServer serves the reload server on the address. The address is bound
+// before returning so that a busy port stops izi with a clear message.
+func startServer(address, option string, handler http.Handler) {
+	listener, err := net.Listen("tcp", address)
 	if err != nil {
-		iziLogger.Log.Errorf("Failed to start up the Reload server: %v", err)
-		return
+		iziLogger.Log.Fatalf("Failed to start up the Reload server on '%s': %v (set '%s' in IZIfile to use another address)", address, err, option)
+	}
+
+	conf := config.Conf.Reload
+	scheme := "http"
+	if conf.CertFile != "" {
+		scheme = "https"
 	}
+	iziLogger.Log.Infof("Reload server listening at %s://%s", scheme, listener.Addr())
+
+	go func() {
+		if conf.CertFile != "" {
+			err = http.ServeTLS(listener, handler, conf.CertFile, conf.KeyFile)
+		} else {
+			err = http.Serve(listener, handler)
+		}
+		iziLogger.Log.Errorf("The Reload server has stopped: %v", err)
+	}()
 }
 
-func sendReload(payload string) {
-	message := bytes.TrimSpace([]byte(payload))
-	broker.broadcast <- message
+// sendReload tells the browsers that the file has changed. Stylesheets and
+// images are swapped in place by LiveReload clients, other files reload the page.
+func sendReload(file string) {
+	ext := strings.ToLower(filepath.Ext(file))
+	broker.broadcast <- &reloadCommand{
+		Command: "reload",
+		Path:    filepath.ToSlash(file),
+		LiveCSS: ext == ".css",
+		LiveImg: imageExts[ext],
+	}
 }
 
 // handleWsRequest handles websocket requests from the peer.
-func handleWsRequest(broker *wsBroker, w http.ResponseWriter, r *http.Request) {
+func handleWsRequest(broker *wsBroker, w http.ResponseWriter, r *http.Request, livereload bool) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		iziLogger.Log.Errorf("error while upgrading server connection: %v", err)
@@ -181,9 +266,10 @@ func handleWsRequest(broker *wsBroker, w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := &wsClient{
-		broker: broker,
-		conn:   conn,
-		send:   make(chan []byte, 256),
+		broker:     broker,
+		conn:       conn,
+		send:       make(chan []byte, 256),
+		livereload: livereload,
 	}
 	client.broker.register <- client
 
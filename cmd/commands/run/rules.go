@@ -0,0 +1,154 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package run
+
+import (
+	"os"
+	"os/exec"
+	path "path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/izi-global/izi/config"
+	iziLogger "github.com/izi-global/izi/logger"
+)
+
+// Actions taken when a watched file changes
+const (
+	actionRebuild = "rebuild" // Rebuild and restart the application
+	actionRestart = "restart" // Restart the existing binary
+	actionReload  = "reload"  // Only reload the browsers
+	actionScript  = "script"  // Run a script
+	actionIgnore  = "ignore"  // Do nothing
+)
+
+// validateRules makes sure every rule in the configuration can be applied.
+func validateRules() {
+	for _, r := range config.Conf.Rules {
+		if _, err := path.Match(r.Pattern, ""); err != nil || r.Pattern == "" {
+			iziLogger.Log.Fatalf("Invalid pattern '%s' in rules", r.Pattern)
+		}
+		switch r.Action {
+		case actionRebuild, actionRestart, actionReload, actionIgnore:
+		case actionScript:
+			if r.Script == "" {
+				iziLogger.Log.Fatalf("Rule '%s' has no script to run", r.Pattern)
+			}
+		default:
+			iziLogger.Log.Fatalf("Unknown action '%s' for rule '%s'", r.Action, r.Pattern)
+		}
+	}
+}
+
+// matchRule returns the action and script of the first rule matching the file.
+func matchRule(filename string) (action, script string, found bool) {
+	rel, err := path.Rel(currpath, filename)
+	if err != nil {
+		rel = filename
+	}
+	rel = path.ToSlash(rel)
+
+	for _, r := range config.Conf.Rules {
+		if matchGlob(r.Pattern, rel) {
+			return r.Action, r.Script, true
+		}
+	}
+	return "", "", false
+}
+
+// matchGlob matches a slash-separated path against a glob pattern. Patterns
+// without a slash match the file name in any directory, and a leading "**/"
+// matches any number of directories.
+func matchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+
+	if strings.HasPrefix(pattern, "**/") {
+		pattern = strings.TrimPrefix(pattern, "**/")
+		for {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+			i := strings.Index(name, "/")
+			if i < 0 {
+				return false
+			}
+			name = name[i+1:]
+		}
+	}
+
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// eventAction decides what to do when the given file changes. Rules from
+// the configuration take precedence over the environment files and the
+// watched extensions.
+func eventAction(filename string) (action, script string) {
+	if action, script, found := matchRule(filename); found {
+		return action, script
+	}
+	if isEnvFile(filename) {
+		return actionRestart, ""
+	}
+	if ifStaticFile(filename) && config.Conf.EnableReload {
+		return actionReload, ""
+	}
+	if shouldIgnoreFile(filename) || !shouldWatchFileWithExtension(filename) {
+		return actionIgnore, ""
+	}
+	return actionRebuild, ""
+}
+
+// hasWatchRule reports whether a rule asks to react to changes of the file.
+func hasWatchRule(filename string) bool {
+	action, _, found := matchRule(filename)
+	return found && action != actionIgnore
+}
+
+// restartApp restarts the application without rebuilding it.
+func restartApp() {
+	state.Lock()
+	defer state.Unlock()
+
+	os.Chdir(currpath)
+	Restart(binaryName())
+}
+
+// runRuleScript runs a script from the configuration, or a shell command.
+func runRuleScript(script string) {
+	command := script
+	if c, exist := config.Conf.Scripts[script]; exist {
+		command = c
+	}
+
+	iziLogger.Log.Infof("Running '%s'...", script)
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.Command("cmd", "/C", command)
+	} else {
+		c = exec.Command("sh", "-c", command)
+	}
+	c.Dir = currpath
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		iziLogger.Log.Errorf("Script '%s' failed: %s", script, err)
+		return
+	}
+	iziLogger.Log.Successf("Script '%s' finished", script)
+}
@@ -0,0 +1,60 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package run
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		// Patterns without a slash match the file name
+		{"*.go", "main.go", true},
+		{"*.go", "controllers/default.go", true},
+		{"*.go", "controllers/default.go.orig", false},
+		{"Makefile", "build/Makefile", true},
+		{"*.tpl", "views/index.html", false},
+		{".env*", ".env.local", true},
+
+		// Patterns with a slash match the path relative to the application
+		{"views/*.tpl", "views/index.tpl", true},
+		{"views/*.tpl", "views/admin/index.tpl", false},
+		{"views/*.tpl", "static/views/index.tpl", false},
+		{"conf/app.conf", "conf/app.conf", true},
+		{"conf/app.conf", "other/conf/app.conf", false},
+
+		// A leading **/ matches any number of directories
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "models/user.go", true},
+		{"**/*.go", "a/b/c/d.go", true},
+		{"**/*.go", "a/b/c/d.js", false},
+		{"**/migrations/*.sql", "database/migrations/1.sql", true},
+		{"**/migrations/*.sql", "migrations/1.sql", true},
+		{"**/migrations/*.sql", "a/b/migrations/1.sql", true},
+		{"**/migrations/*.sql", "migrations/old/1.sql", false},
+		{"**/migrations/*.sql", "database/migrations_old/1.sql", false},
+
+		// Malformed patterns match nothing
+		{"[", "[", false},
+		{"views/[", "views/[", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %t, want %t", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
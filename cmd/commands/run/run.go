@@ -28,11 +28,68 @@ import (
 )
 
 var CmdRun = &commands.Command{
-	UsageLine: "run [appname] [watchall] [-main=*.go] [-downdoc=true]  [-gendoc=true] [-vendor=true] [-e=folderToExclude] [-ex=extraPackageToWatch] [-tags=goBuildTags] [-runmode=IZIGO_RUNMODE]",
+	UsageLine: "run [appname] [watchall] [-main=*.go] [-downdoc=true]  [-gendoc=true] [-vendor=true] [-e=folderToExclude] [-ex=extraPackageToWatch] [-tags=goBuildTags] [-runmode=IZIGO_RUNMODE] [-restart=true] [-maxretries=5] [-keys=false] [-race] [-cover] [-ldflags=flags] [-gcflags=flags] [-trimpath] [-ba=buildArgs] [-bincache] [-env-file=.env] [-proxy=:8000]",
 	Short:     "Run the application by starting a local development server",
 	Long: `
 Run command will supervise the filesystem of the application for any changes, and recompile/restart it.
 
+When the application exits with an error, izi reports the exit status with the last lines
+of its output. With -restart (or "restart.enable" in IZIfile) the application is started
+again with an exponential backoff until the next successful build.
+
+When "readiness.address" is set in IZIfile, izi waits for the application to accept
+connections on that address (or to answer "readiness.path" with "readiness.status")
+before reporting it as running and reloading the browsers.
+
+The "rules" list in IZIfile maps glob patterns, relative to the application path, to the
+action taken when a matching file changes: "rebuild", "restart" (without rebuilding),
+"reload" (browsers only), "script" (runs "script", a name from "scripts" or a shell command)
+or "ignore". The first matching rule wins, e.g.:
+
+  rules:
+    - pattern: "conf/app.conf"
+      action: restart
+    - pattern: "views/*.tpl"
+      action: reload
+
+While running in a terminal, single keys control izi: "r" rebuilds, "R" restarts without
+building, "t" runs the tests, "c" clears the screen, "l" toggles the application logs,
+"p" pauses or resumes watching and "q" quits. Use -keys=false to disable them.
+
+The "build" section of IZIfile sets the options of "go build": race, cover, cover_dir,
+ldflags, gcflags, trimpath and bin_cache, and "build_args" lists extra arguments. The
+command line flags take precedence. In -ldflags, {{"{{.Version}}"}}, {{"{{.Commit}}"}} and
+{{"{{.Date}}"}} are replaced with the output of "git describe", the commit and the build date.
+Coverage binaries are interrupted instead of killed so that they can write their profiles.
+
+The dotenv files listed in "env_files" (default: .env and .env.local) are loaded into the
+application environment after "envs", later files overriding earlier ones. They support
+quoted and multiline values and ${VAR} expansion, and the application is restarted without
+rebuilding when one of them changes. Use -env-file (repeatable) to load other files instead.
+
+With "enable_reload", the reload server speaks the LiveReload protocol on :35729/livereload,
+so the LiveReload browser extensions can connect, and serves its own client: add
+<script src="http://localhost:35729/livereload.js"></script> to the pages. Stylesheets and
+images are swapped in place, other changes reload the page. The legacy /reload websocket on
+:12450 still receives the name of the changed file.
+
+The "reload" section of IZIfile sets the server "address" (default :12450), the legacy
+websocket "path", the "livereload_address" and a TLS "cert_file" and "key_file". Both
+addresses also serve a dashboard with the build history at /, the build state as JSON at
+/status and its changes as server-sent events at /events.
+
+With -proxy (or "proxy.address"), izi serves the application through a development proxy
+forwarding to "proxy.target" (default: "readiness.address"). When the reload server is
+enabled, the proxy injects the LiveReload client into the HTML pages, so the application
+needs no change; set "proxy.inject" to false to disable it.
+
+With -downdoc, the Swagger UI bundled in izi is installed into the "swagger" folder when it
+is missing. To download another distribution instead, set "swagger.url" to a zip archive and
+"swagger.sha256" to its checksum in IZIfile.
+
+With -gendoc, the docs are generated when the controllers or the routers change, and the
+reload server serves the Swagger UI with the current swagger.json at /swagger/.
+
 `,
 	PreRun: func(cmd *commands.Command, args []string) { version.ShowShortVersionBanner() },
 	Run:    RunApp,
@@ -62,19 +119,49 @@ var (
 	runargs string
 	// Extra directories
 	extraPackages utils.StrFlags
+	// Restart the application when it crashes
+	autoRestart bool
+	// Maximum number of restarts after a crash
+	maxRetries int
+	// Enable the keyboard controls
+	interactive bool
+	// Pass through to "go build"
+	raceBuild  bool
+	coverBuild bool
+	trimpath   bool
+	ldflags    string
+	gcflags    string
+	buildArgs  string
+	// Write the binary to the user cache directory
+	binCache bool
+	// Dotenv files overriding "env_files"
+	envFiles utils.StrFlags
+	// Address of the development proxy
+	proxyAddress string
 )
-var started = make(chan bool)
 
 func init() {
 	CmdRun.Flag.Var(&mainFiles, "main", "Specify main go files.")
 	CmdRun.Flag.Var(&gendoc, "gendoc", "Enable auto-generate the docs.")
-	CmdRun.Flag.Var(&downdoc, "downdoc", "Enable auto-download of the swagger file if it does not exist.")
+	CmdRun.Flag.Var(&downdoc, "downdoc", "Enable auto-install of the swagger UI if it does not exist.")
 	CmdRun.Flag.Var(&excludedPaths, "e", "List of paths to exclude.")
 	CmdRun.Flag.BoolVar(&vendorWatch, "vendor", false, "Enable watch vendor folder.")
 	CmdRun.Flag.StringVar(&buildTags, "tags", "", "Set the build tags. See: https://golang.org/pkg/go/build/")
 	CmdRun.Flag.StringVar(&runmode, "runmode", "", "Set the IZIGo run mode.")
 	CmdRun.Flag.StringVar(&runargs, "runargs", "", "Extra args to run application")
 	CmdRun.Flag.Var(&extraPackages, "ex", "List of extra package to watch.")
+	CmdRun.Flag.BoolVar(&autoRestart, "restart", false, "Restart the application with an exponential backoff when it crashes.")
+	CmdRun.Flag.BoolVar(&raceBuild, "race", false, "Build the application with the race detector.")
+	CmdRun.Flag.BoolVar(&coverBuild, "cover", false, "Build a coverage binary, profiles are written to build.cover_dir.")
+	CmdRun.Flag.BoolVar(&trimpath, "trimpath", false, "Remove file system paths from the application binary.")
+	CmdRun.Flag.StringVar(&ldflags, "ldflags", "", "Set the linker flags, {{.Version}}, {{.Commit}} and {{.Date}} are replaced.")
+	CmdRun.Flag.StringVar(&gcflags, "gcflags", "", "Set the compiler flags.")
+	CmdRun.Flag.StringVar(&buildArgs, "ba", "", "Specify additional args for Go build.")
+	CmdRun.Flag.BoolVar(&binCache, "bincache", false, "Write the application binary to the user cache directory.")
+	CmdRun.Flag.Var(&envFiles, "env-file", "Load the application environment from this dotenv file instead of env_files.")
+	CmdRun.Flag.StringVar(&proxyAddress, "proxy", "", "Start a development proxy in front of the application on this address.")
+	CmdRun.Flag.BoolVar(&interactive, "keys", true, "Enable keyboard controls when running in a terminal.")
+	CmdRun.Flag.IntVar(&maxRetries, "maxretries", 0, "Maximum number of restarts after a crash (defaults to restart.max_retries).")
 	exit = make(chan bool)
 	commands.AvailableCommands = append(commands.AvailableCommands, CmdRun)
 }
@@ -121,6 +208,8 @@ func RunApp(cmd *commands.Command, args []string) int {
 		iziLogger.Log.Warnf("Using '%s' as 'runmode'", os.Getenv("IZIGO_RUNMODE"))
 	}
 
+	validateRules()
+
 	var paths []string
 	readAppDirectories(currpath, &paths)
 
@@ -161,16 +250,16 @@ func RunApp(cmd *commands.Command, args []string) int {
 	if downdoc == "true" {
 		if _, err := os.Stat(path.Join(currpath, "swagger", "index.html")); err != nil {
 			if os.IsNotExist(err) {
-				downloadFromURL(swaggerlink, "swagger.zip")
-				unzipAndDelete("swagger.zip")
+				installSwagger()
 			}
 		}
 	}
 
-	// Start the Reload server (if enabled)
-	if config.Conf.EnableReload {
+	// Start the Reload server (if enabled), it also serves the generated docs
+	if config.Conf.EnableReload || gendoc == "true" {
 		startReloadServer()
 	}
+	startProxy()
 	if gendoc == "true" {
 		NewWatcher(paths, files, true)
 		AutoBuild(files, true)
@@ -179,6 +268,10 @@ func RunApp(cmd *commands.Command, args []string) int {
 		AutoBuild(files, false)
 	}
 
+	if interactive {
+		startKeyboardControls(files, gendoc == "true")
+	}
+
 	for {
 		<-exit
 		runtime.Goexit()
@@ -217,7 +310,8 @@ func readAppDirectories(directory string, paths *[]string) {
 			continue
 		}
 
-		if path.Ext(fileInfo.Name()) == ".go" || (ifStaticFile(fileInfo.Name()) && config.Conf.EnableReload) {
+		if path.Ext(fileInfo.Name()) == ".go" || (ifStaticFile(fileInfo.Name()) && config.Conf.EnableReload) ||
+			hasWatchRule(path.Join(directory, fileInfo.Name())) || isEnvFile(path.Join(directory, fileInfo.Name())) {
 			*paths = append(*paths, directory)
 			useDirectory = true
 		}
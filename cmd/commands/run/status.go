@@ -0,0 +1,235 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// States of the supervised application
+const (
+	stateBuilding = "building"
+	stateFailed   = "failed"
+	stateStarting = "starting"
+	stateRunning  = "running"
+	stateCrashed  = "crashed"
+	stateStopped  = "stopped"
+)
+
+// Number of builds kept in the history
+const buildHistorySize = 20
+
+// buildRecord describes a finished build.
+type buildRecord struct {
+	Started    time.Time `json:"started"`
+	DurationMs int64     `json:"duration_ms"`
+	Success    bool      `json:"success"`
+	Output     string    `json:"output,omitempty"`
+}
+
+// appStatus is the state reported by /status and /events.
+type appStatus struct {
+	State     string        `json:"state"`
+	PID       int           `json:"pid,omitempty"`
+	Errors    string        `json:"errors,omitempty"`
+	LastBuild *buildRecord  `json:"last_build,omitempty"`
+	History   []buildRecord `json:"history"`
+}
+
+var (
+	statusLock   sync.Mutex
+	status       = appStatus{State: stateStopped, History: []buildRecord{}}
+	buildStarted time.Time
+	// Channels of the clients listening to /events
+	subscribers = make(map[chan []byte]bool)
+)
+
+// setState updates the application state and notifies the subscribers.
+func setState(state string, pid int, errors string) {
+	statusLock.Lock()
+	defer statusLock.Unlock()
+
+	status.State = state
+	status.PID = pid
+	status.Errors = errors
+	publish()
+}
+
+// buildStarting records the beginning of a build.
+func buildStarting() {
+	statusLock.Lock()
+	buildStarted = time.Now()
+	statusLock.Unlock()
+	setState(stateBuilding, 0, "")
+}
+
+// buildFinished records the result and the output of the current build.
+func buildFinished(success bool, output string) {
+	statusLock.Lock()
+	defer statusLock.Unlock()
+
+	record := buildRecord{
+		Started:    buildStarted,
+		DurationMs: int64(time.Since(buildStarted) / time.Millisecond),
+		Success:    success,
+		Output:     output,
+	}
+	status.History = append([]buildRecord{record}, status.History...)
+	if len(status.History) > buildHistorySize {
+		status.History = status.History[:buildHistorySize]
+	}
+	status.LastBuild = &status.History[0]
+
+	if !success {
+		status.State = stateFailed
+		status.PID = 0
+		status.Errors = output
+	}
+	publish()
+}
+
+// publish sends the current status to the subscribers.
+// It must be called with statusLock held.
+func publish() {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return
+	}
+	for ch := range subscribers {
+		select {
+		case ch <- data:
+		default:
+			// Slow client, it will get the next update
+		}
+	}
+}
+
+func serveStatus(w http.ResponseWriter, r *http.Request) {
+	statusLock.Lock()
+	data, err := json.Marshal(status)
+	statusLock.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Write(data)
+}
+
+// serveEvents streams the status changes as server-sent events.
+func serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := make(chan []byte, 16)
+	statusLock.Lock()
+	subscribers[ch] = true
+	current, _ := json.Marshal(status)
+	statusLock.Unlock()
+	defer func() {
+		statusLock.Lock()
+		delete(subscribers, ch)
+		statusLock.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	fmt.Fprintf(w, "event: status\ndata: %s\n\n", current)
+	flusher.Flush()
+
+	for {
+		select {
+		case data := <-ch:
+			fmt.Fprintf(w, "event: status\ndata: %s\n\n", data)
+			flusher.Flush()
+		case <-time.After(pongWait):
+			// Keep the connection alive through proxies
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func serveDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, dashboardHTML, html.EscapeString(appname))
+}
+
+// dashboardHTML shows the state of the application, the build history and
+// the output of the last build, updated from /events.
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>izi - %s</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+.state { display: inline-block; padding: .2em .6em; border-radius: 4px; color: #fff; background: #888; }
+.building, .starting { background: #d90; }
+.running { background: #2a2; }
+.failed, .crashed { background: #c22; }
+table { border-collapse: collapse; margin-top: 1em; }
+td, th { padding: .3em .8em; border-bottom: 1px solid #ddd; text-align: left; }
+pre { background: #f4f4f4; padding: 1em; overflow: auto; }
+</style>
+</head>
+<body>
+<h2>izi run</h2>
+<p>State: <span id="state" class="state">-</span> <span id="pid"></span></p>
+<h3>Last build output</h3>
+<pre id="output">-</pre>
+<h3>Build history</h3>
+<table>
+<thead><tr><th>Started</th><th>Duration</th><th>Result</th></tr></thead>
+<tbody id="history"></tbody>
+</table>
+<script>
+function render(s) {
+  var state = document.getElementById('state');
+  state.textContent = s.state;
+  state.className = 'state ' + s.state;
+  document.getElementById('pid').textContent = s.pid ? 'pid ' + s.pid : '';
+  var out = s.last_build ? (s.last_build.output || 'Built successfully') : '-';
+  document.getElementById('output').textContent = s.errors || out;
+  var rows = (s.history || []).map(function (b) {
+    return '<tr><td>' + new Date(b.started).toLocaleTimeString() + '</td><td>' +
+      b.duration_ms + ' ms</td><td>' + (b.success ? 'ok' : 'failed') + '</td></tr>';
+  });
+  document.getElementById('history').innerHTML = rows.join('');
+}
+var events = new EventSource('/events');
+events.addEventListener('status', function (e) { render(JSON.parse(e.data)); });
+</script>
+</body>
+</html>
+`
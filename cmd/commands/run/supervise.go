@@ -0,0 +1,209 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package run
+
+import (
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/izi-global/izi/config"
+	iziLogger "github.com/izi-global/izi/logger"
+	"github.com/izi-global/izi/utils"
+)
+
+// Number of stderr lines reported when the application crashes
+const stderrTailLines = 20
+
+var (
+	procLock sync.Mutex
+	// Processes killed on purpose by izi, their exit is not a crash
+	killedCmds = make(map[*exec.Cmd]bool)
+	// Consecutive crashes since the last successful build
+	crashes int
+	// Incremented on every successful build to cancel pending restarts
+	buildGeneration int
+)
+
+// tailBuffer is an io.Writer that keeps the last lines written to it.
+type tailBuffer struct {
+	mu      sync.Mutex
+	max     int
+	lines   []string
+	partial string
+}
+
+func newTailBuffer(max int) *tailBuffer {
+	return &tailBuffer{max: max}
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	data := t.partial + string(p)
+	parts := strings.Split(data, "\n")
+	t.partial = parts[len(parts)-1]
+	for _, line := range parts[:len(parts)-1] {
+		t.lines = append(t.lines, strings.TrimRight(line, "\r"))
+	}
+	if len(t.lines) > t.max {
+		t.lines = t.lines[len(t.lines)-t.max:]
+	}
+	return len(p), nil
+}
+
+// String returns the retained lines, including an unterminated last line.
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lines := t.lines
+	if t.partial != "" {
+		lines = append(lines[:len(lines):len(lines)], t.partial)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// markKilled records that c is being stopped by izi itself.
+func markKilled(c *exec.Cmd) {
+	procLock.Lock()
+	defer procLock.Unlock()
+	killedCmds[c] = true
+}
+
+// forgetKilled forgets c, which had exited before izi stopped it.
+func forgetKilled(c *exec.Cmd) {
+	procLock.Lock()
+	defer procLock.Unlock()
+	delete(killedCmds, c)
+}
+
+// wasKilled reports whether c was stopped by izi and forgets about it.
+func wasKilled(c *exec.Cmd) bool {
+	procLock.Lock()
+	defer procLock.Unlock()
+	killed := killedCmds[c]
+	delete(killedCmds, c)
+	return killed
+}
+
+// resetCrashes is called after a successful build: the crash counter starts
+// over and any restart still waiting for its backoff delay is cancelled.
+func resetCrashes() {
+	procLock.Lock()
+	defer procLock.Unlock()
+	crashes = 0
+	buildGeneration++
+}
+
+// waitApp waits for the application process to exit and reports crashes.
+// The done channel is closed as soon as the process has exited.
+func waitApp(c *exec.Cmd, appname string, stderr *tailBuffer, done chan struct{}) {
+	err := c.Wait()
+	close(done)
+	if wasKilled(c) {
+		return
+	}
+
+	if err == nil {
+		setState(stateStopped, 0, "")
+		iziLogger.Log.Warnf("'%s' has exited", appname)
+		return
+	}
+
+	setState(stateCrashed, 0, err.Error()+"\n"+stderr.String())
+	iziLogger.Log.Errorf("'%s' has crashed: %s", appname, err)
+	if tail := stderr.String(); tail != "" {
+		iziLogger.Log.Errorf("Last lines of the application output:\n%s", tail)
+	}
+	utils.Notify(lastLine(stderr.String()), "Application crashed")
+
+	scheduleRestart(appname)
+}
+
+// scheduleRestart starts the application again after an exponential backoff,
+// until the maximum number of retries is reached.
+func scheduleRestart(appname string) {
+	if !autoRestart && !config.Conf.Restart.Enable {
+		iziLogger.Log.Hint("Waiting for file changes to rebuild the application...")
+		return
+	}
+
+	procLock.Lock()
+	crashes++
+	attempt, generation := crashes, buildGeneration
+	procLock.Unlock()
+
+	retries := maxRetries
+	if retries <= 0 {
+		retries = config.Conf.Restart.MaxRetries
+	}
+	if attempt > retries {
+		iziLogger.Log.Errorf("'%s' crashed %d times in a row, waiting for the next successful build", appname, retries)
+		return
+	}
+
+	delay := restartDelay(attempt)
+	iziLogger.Log.Infof("Restarting '%s' in %s (attempt %d/%d)...", appname, delay, attempt, retries)
+	time.Sleep(delay)
+
+	// Do not race with a rebuild that may be restarting the application
+	state.Lock()
+	defer state.Unlock()
+	procLock.Lock()
+	cancelled := generation != buildGeneration
+	procLock.Unlock()
+	if cancelled {
+		return
+	}
+	Start(appname)
+}
+
+// restartDelay returns the backoff delay for the given restart attempt.
+func restartDelay(attempt int) time.Duration {
+	backoff := parseDuration(config.Conf.Restart.Backoff, time.Second)
+	maxBackoff := parseDuration(config.Conf.Restart.MaxBackoff, 30*time.Second)
+
+	delay := backoff
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+func parseDuration(value string, fallback time.Duration) time.Duration {
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		iziLogger.Log.Warnf("Invalid duration '%s', using %s", value, fallback)
+		return fallback
+	}
+	return d
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndex(s, "\n"); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
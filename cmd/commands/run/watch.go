@@ -16,8 +16,11 @@ package run
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"os"
 	"os/exec"
+	path "path/filepath"
 	"regexp"
 	"runtime"
 	"strings"
@@ -33,6 +36,7 @@ import (
 
 var (
 	cmd                 *exec.Cmd
+	cmdDone             chan struct{} // Closed when cmd has exited
 	state               sync.Mutex
 	eventTime           = make(map[string]int64)
 	scheduleTime        time.Time
@@ -60,15 +64,17 @@ func NewWatcher(paths []string, files []string, isgenerate bool) {
 			case e := <-watcher.Events:
 				isBuild := true
 
-				if ifStaticFile(e.Name) && config.Conf.EnableReload {
-					sendReload(e.String())
+				if isWatchPaused() {
 					continue
 				}
-				// Skip ignored files
-				if shouldIgnoreFile(e.Name) {
+				action, script := eventAction(e.Name)
+				if action == actionIgnore {
 					continue
 				}
-				if !shouldWatchFileWithExtension(e.Name) {
+				if action == actionReload {
+					if config.Conf.EnableReload {
+						sendReload(e.Name)
+					}
 					continue
 				}
 
@@ -86,12 +92,21 @@ func NewWatcher(paths []string, files []string, isgenerate bool) {
 						// Wait 1s before autobuild until there is no file change.
 						scheduleTime = time.Now().Add(1 * time.Second)
 						time.Sleep(scheduleTime.Sub(time.Now()))
-						AutoBuild(files, isgenerate)
+						switch action {
+						case actionRestart:
+							restartApp()
+						case actionScript:
+							runRuleScript(script)
+						default:
+							AutoBuild(files, isgenerate && isDocsSource(e.Name))
+						}
 
 						if config.Conf.EnableReload {
-							// Wait 100ms more before refreshing the browser
-							time.Sleep(100 * time.Millisecond)
-							sendReload(e.String())
+							if !readinessEnabled() {
+								// Wait 100ms more before refreshing the browser
+								time.Sleep(100 * time.Millisecond)
+							}
+							sendReload(e.Name)
 						}
 					}()
 				}
@@ -117,6 +132,7 @@ func AutoBuild(files []string, isgenerate bool) {
 	defer state.Unlock()
 
 	os.Chdir(currpath)
+	buildStarting()
 
 	cmdName := "go"
 
@@ -135,29 +151,16 @@ func AutoBuild(files []string, isgenerate bool) {
 	}
 
 	if isgenerate {
-		iziLogger.Log.Info("Generating the docs...")
-		icmd := exec.Command("izi", "generate", "docs")
-		icmd.Env = append(os.Environ(), "GOGC=off")
-		err = icmd.Run()
-		if err != nil {
-			utils.Notify("", "Failed to generate the docs.")
-			iziLogger.Log.Errorf("Failed to generate the docs.")
-			return
-		}
-		iziLogger.Log.Success("Docs generated!")
+		generateDocs()
 	}
-	appName := appname
+	appName := binaryName()
 	if err == nil {
-
-		if runtime.GOOS == "windows" {
-			appName += ".exe"
-		}
-
 		args := []string{"build"}
 		args = append(args, "-o", appName)
 		if buildTags != "" {
 			args = append(args, "-tags", buildTags)
 		}
+		args = append(args, buildFlags()...)
 		args = append(args, files...)
 
 		bcmd := exec.Command(cmdName, args...)
@@ -167,14 +170,30 @@ func AutoBuild(files []string, isgenerate bool) {
 		if err != nil {
 			utils.Notify(stderr.String(), "Build Failed")
 			iziLogger.Log.Errorf("Failed to build the application: %s", stderr.String())
+			buildFinished(false, stderr.String())
 			return
 		}
 	}
 
 	iziLogger.Log.Success("Built Successfully!")
+	buildFinished(true, stderr.String())
+	resetCrashes()
 	Restart(appName)
 }
 
+// binaryName returns the file name of the application binary,
+// or its full path when it is written to the cache directory
+func binaryName() string {
+	name := appname
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	if dir := binaryDir(); dir != currpath {
+		return path.Join(dir, name)
+	}
+	return name
+}
+
 // Kill kills the running command process
 func Kill() {
 	defer func() {
@@ -182,9 +201,25 @@ func Kill() {
 			iziLogger.Log.Infof("Kill recover: %s", e)
 		}
 	}()
-	if cmd != nil && cmd.Process != nil {
-		err := cmd.Process.Kill()
-		if err != nil {
+	procLock.Lock()
+	c, done := cmd, cmdDone
+	procLock.Unlock()
+	if c != nil && c.Process != nil {
+		markKilled(c)
+		// Coverage binaries write their profiles when they exit normally,
+		// give the application a chance to shut down before killing it.
+		if coverEnabled() && runtime.GOOS != "windows" && c.Process.Signal(os.Interrupt) == nil {
+			select {
+			case <-done:
+				return
+			case <-time.After(5 * time.Second):
+			}
+		}
+		err := c.Process.Kill()
+		if errors.Is(err, os.ErrProcessDone) {
+			// waitApp is done with the process, it had exited on its own
+			forgetKilled(c)
+		} else if err != nil {
 			iziLogger.Log.Errorf("Error while killing cmd process: %s", err)
 		}
 	}
@@ -194,31 +229,65 @@ func Kill() {
 func Restart(appname string) {
 	iziLogger.Log.Debugf("Kill running process", utils.FILE(), utils.LINE())
 	Kill()
-	go Start(appname)
+	Start(appname)
 }
 
-// Start starts the command process
+// Start starts the command process. When a readiness probe is configured,
+// it returns once the application is ready to serve requests.
 func Start(appname string) {
 	iziLogger.Log.Infof("Restarting '%s'...", appname)
-	if !strings.Contains(appname, "./") {
+	if !path.IsAbs(appname) && !strings.Contains(appname, "./") {
 		appname = "./" + appname
 	}
 
-	cmd = exec.Command(appname)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	stderr := newTailBuffer(stderrTailLines)
+	c := exec.Command(appname)
+	c.Stdout = appStdout
+	c.Stderr = io.MultiWriter(appStderr, stderr)
 	if runargs != "" {
 		r := regexp.MustCompile("'.+'|\".+\"|\\S+")
 		m := r.FindAllString(runargs, -1)
-		cmd.Args = append([]string{appname}, m...)
+		c.Args = append([]string{appname}, m...)
 	} else {
-		cmd.Args = append([]string{appname}, config.Conf.CmdArgs...)
+		c.Args = append([]string{appname}, config.Conf.CmdArgs...)
+	}
+	c.Env = append(os.Environ(), config.Conf.Envs...)
+	c.Env = append(c.Env, loadEnvFiles()...)
+	if coverEnabled() {
+		os.MkdirAll(coverDir(), 0755)
+		c.Env = append(c.Env, "GOCOVERDIR="+coverDir())
+	}
+
+	if err := c.Start(); err != nil {
+		utils.Notify(err.Error(), "Failed to start the application")
+		iziLogger.Log.Errorf("Failed to start '%s': %s", appname, err)
+		setState(stateFailed, 0, err.Error())
+		return
+	}
+	done := make(chan struct{})
+	procLock.Lock()
+	cmd, cmdDone = c, done
+	procLock.Unlock()
+
+	go waitApp(c, appname, stderr, done)
+
+	if !readinessEnabled() {
+		setState(stateRunning, c.Process.Pid, "")
+		iziLogger.Log.Successf("'%s' is running...", appname)
+		return
 	}
-	cmd.Env = append(os.Environ(), config.Conf.Envs...)
 
-	go cmd.Run()
-	iziLogger.Log.Successf("'%s' is running...", appname)
-	started <- true
+	setState(stateStarting, c.Process.Pid, "")
+	begin := time.Now()
+	if err := waitReady(done); err != nil {
+		iziLogger.Log.Errorf("'%s' is not ready: %s", appname, err)
+		if err != errAppExited {
+			setState(stateFailed, c.Process.Pid, err.Error())
+		}
+		return
+	}
+	setState(stateRunning, c.Process.Pid, "")
+	iziLogger.Log.Successf("'%s' is running (ready in %s)", appname, time.Since(begin))
 }
 
 func ifStaticFile(filename string) bool {
@@ -19,8 +19,10 @@ import (
 	"github.com/izi-global/izi/cmd/commands"
 	_ "github.com/izi-global/izi/cmd/commands/api"
 	_ "github.com/izi-global/izi/cmd/commands/bale"
+	_ "github.com/izi-global/izi/cmd/commands/db"
 	_ "github.com/izi-global/izi/cmd/commands/dlv"
 	_ "github.com/izi-global/izi/cmd/commands/dockerize"
+	_ "github.com/izi-global/izi/cmd/commands/docs"
 	_ "github.com/izi-global/izi/cmd/commands/generate"
 	_ "github.com/izi-global/izi/cmd/commands/hprose"
 	_ "github.com/izi-global/izi/cmd/commands/izifix"
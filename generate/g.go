@@ -18,7 +18,13 @@ import "github.com/izi-global/izi/utils"
 
 var SQLDriver utils.DocValue
 var SQLConn utils.DocValue
+var SQLConnName utils.DocValue
 var Level utils.DocValue
 var Tables utils.DocValue
 var Fields utils.DocValue
 var DDL utils.DocValue
+var AddFields utils.DocValue
+var DropFields utils.DocValue
+var Rename utils.DocValue
+var AddIndex utils.DocValue
+var AddUnique utils.DocValue
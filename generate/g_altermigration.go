@@ -0,0 +1,211 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/izi-global/izi/logger"
+	"github.com/izi-global/izi/utils"
+)
+
+// Go types of the field types accepted by -fields
+var fieldTypes = map[string]string{
+	"string":   "string",
+	"text":     "string",
+	"datetime": "time.Time",
+	"bool":     "bool",
+	"float":    "float64",
+	"float32":  "float32",
+	"float64":  "float64",
+	"int":      "int",
+	"int8":     "int8",
+	"int16":    "int16",
+	"int32":    "int32",
+	"int64":    "int64",
+	"uint":     "uint",
+	"uint8":    "uint8",
+	"uint16":   "uint16",
+	"uint32":   "uint32",
+	"uint64":   "uint64",
+}
+
+// Words introducing the table in migration names, e.g. add_email_to_users
+var tableNameSeparators = []string{"_to_", "_from_", "_on_", "_in_"}
+
+// IsAlterMigration reports whether an option altering a table is set.
+func IsAlterMigration() bool {
+	return AddFields != "" || DropFields != "" || Rename != "" || AddIndex != "" || AddUnique != ""
+}
+
+// GenerateAlterMigration returns the Up and Down code of the migration
+// altering a table with the -add-fields, -drop-fields, -rename, -add-index
+// and -add-unique options. The table is the one of -tables or the one named
+// at the end of the migration name, e.g. users for add_email_to_users.
+func GenerateAlterMigration(mname, driver, tables string) (upsql, downsql string) {
+	table := alterTableName(mname, tables)
+	m := newDBDriver(driver)
+	iziLogger.Log.Infof("Using '%s' as table name", table)
+
+	var changes []*schemaChange
+	for _, pair := range splitList(Rename.String()) {
+		kv := strings.SplitN(pair, ":", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			iziLogger.Log.Fatal("Rename format is wrong. Should be: old:new,old:new " + pair)
+		}
+		from, to := utils.SnakeString(kv[0]), utils.SnakeString(kv[1])
+		changes = append(changes, &schemaChange{
+			description: fmt.Sprintf("Rename column %s.%s to %s", table, from, to),
+			note:        requirementNote(m, OpRenameColumn),
+			up:          []string{m.RenameColumn(table, from, to)},
+			down:        []string{m.RenameColumn(table, to, from)},
+		})
+	}
+	for _, col := range fieldColumns(m, DropFields.String(), "Drop fields") {
+		changes = append(changes, &schemaChange{
+			description: "Drop column " + table + "." + col.Tag.Column,
+			note:        joinNotes(requirementNote(m, OpDropColumn), "the column is added back empty by Down"),
+			up:          []string{m.DropColumn(table, col.Tag.Column)},
+			down:        []string{m.AddColumn(table, col, nil)},
+		})
+	}
+	for _, col := range fieldColumns(m, AddFields.String(), "Add fields") {
+		changes = append(changes, &schemaChange{
+			description: "Add column " + table + "." + col.Tag.Column + " " + columnSummary(col),
+			note:        downRequirementNote(m, OpDropColumn),
+			up:          []string{m.AddColumn(table, col, nil)},
+			down:        []string{m.DropColumn(table, col.Tag.Column)},
+		})
+	}
+	for _, index := range []*Index{alterIndex(table, AddIndex.String(), false), alterIndex(table, AddUnique.String(), true)} {
+		if index == nil {
+			continue
+		}
+		changes = append(changes, &schemaChange{
+			description: "Create index " + index.Name,
+			up:          []string{m.CreateIndex(table, index)},
+			down:        []string{m.DropIndex(table, index)},
+		})
+	}
+
+	for _, c := range changes {
+		upsql += c.code(c.up, false)
+	}
+	for i := len(changes) - 1; i >= 0; i-- {
+		downsql += changes[i].code(changes[i].down, true)
+	}
+	return
+}
+
+// alterTableName returns the altered table, the one of -tables or the one
+// ending the migration name.
+func alterTableName(mname, tables string) string {
+	if names := splitList(tables); len(names) > 1 {
+		iziLogger.Log.Fatal("A migration altering a table takes a single table name")
+	} else if len(names) == 1 {
+		return names[0]
+	}
+	end := -1
+	for _, sep := range tableNameSeparators {
+		if i := strings.LastIndex(mname, sep); i >= 0 && i+len(sep) > end {
+			end = i + len(sep)
+		}
+	}
+	if end < 0 || end == len(mname) {
+		iziLogger.Log.Hint("Name the migration like add_email_to_users or use -tables=users")
+		iziLogger.Log.Fatalf("Could not find the table altered by migration '%s'", mname)
+	}
+	return mname[end:]
+}
+
+// fieldColumns returns the nullable columns of the name:type[:size] fields.
+func fieldColumns(m DBDriver, fields, option string) []*Column {
+	var columns []*Column
+	for _, field := range splitList(fields) {
+		kv := strings.SplitN(field, ":", 3)
+		if len(kv) < 2 || fieldTypes[kv[1]] == "" {
+			iziLogger.Log.Hint("The columns are defined like in -fields, the size of strings is optional")
+			iziLogger.Log.Fatalf("%s format is wrong. Should be: key:type,key:type:size %s", option, field)
+		}
+		tag := &OrmTag{Column: utils.SnakeString(kv[0]), Null: true}
+		switch {
+		case kv[1] == "text":
+			tag.Type = "text"
+		case len(kv) == 3:
+			tag.Size = kv[2]
+		case kv[1] == "string":
+			tag.Size = "128"
+		}
+		col := &Column{Name: utils.CamelCase(kv[0]), Type: fieldTypes[kv[1]], Tag: tag}
+		col.SQLType = m.ColumnType(col)
+		columns = append(columns, col)
+	}
+	return columns
+}
+
+// alterIndex returns the index of the comma separated columns, none when empty.
+func alterIndex(table, columns string, unique bool) *Index {
+	names := splitList(columns)
+	if len(names) == 0 {
+		return nil
+	}
+	for i, name := range names {
+		names[i] = utils.SnakeString(name)
+	}
+	prefix := "idx_"
+	if unique {
+		prefix = "uniq_"
+	}
+	return &Index{Name: prefix + table + "_" + strings.Join(names, "_"), Columns: names, Unique: unique}
+}
+
+// requirementNote returns the note of the database version the operation
+// needs, empty when every version runs it.
+func requirementNote(m DBDriver, op string) string {
+	if version := m.Requires(op); version != "" {
+		return op + " needs " + version + " or later"
+	}
+	return ""
+}
+
+// downRequirementNote returns the note of the database version the
+// operation of the Down method needs.
+func downRequirementNote(m DBDriver, op string) string {
+	if note := requirementNote(m, op); note != "" {
+		return "Down: " + note
+	}
+	return ""
+}
+
+func joinNotes(notes ...string) string {
+	var nonEmpty []string
+	for _, note := range notes {
+		if note != "" {
+			nonEmpty = append(nonEmpty, note)
+		}
+	}
+	return strings.Join(nonEmpty, ", ")
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/izi-global/izi/logger/colors"
 	"github.com/izi-global/izi/utils"
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 const (
@@ -41,6 +42,7 @@ type DbTransformer interface {
 	GetTableNames(conn *sql.DB) []string
 	GetConstraints(conn *sql.DB, table *Table, blackList map[string]bool)
 	GetColumns(conn *sql.DB, table *Table, blackList map[string]bool)
+	GetIndexes(conn *sql.DB, table *Table)
 	GetGoDataType(sqlType string) (string, error)
 }
 
@@ -52,10 +54,21 @@ type MysqlDB struct {
 type PostgresDB struct {
 }
 
+// SqliteDB is the SQLite version of DbTransformer
+type SqliteDB struct {
+}
+
 // dbDriver maps a DBMS name to its version of DbTransformer
 var dbDriver = map[string]DbTransformer{
 	"mysql":    &MysqlDB{},
 	"postgres": &PostgresDB{},
+	"sqlite":   &SqliteDB{},
+}
+
+// GetDbTransformer returns the DbTransformer of the DBMS
+func GetDbTransformer(dbms string) (DbTransformer, bool) {
+	trans, ok := dbDriver[dbms]
+	return trans, ok
 }
 
 type MvcPath struct {
@@ -138,6 +151,42 @@ var typeMappingPostgres = map[string]string{
 	"inet":                        "string",  // ip address
 }
 
+// typeMappingSqlite maps SQLite declared types to corresponding Go data type,
+// other types are mapped from their SQLite type affinity
+var typeMappingSqlite = map[string]string{
+	"integer":           "int", // int
+	"int":               "int",
+	"tinyint":           "int8",
+	"smallint":          "int16",
+	"int2":              "int16",
+	"mediumint":         "int32",
+	"bigint":            "int64",
+	"int8":              "int64",
+	"unsigned big int":  "uint64",
+	"boolean":           "bool", // bool
+	"bool":              "bool",
+	"char":              "string", // string
+	"character":         "string",
+	"varchar":           "string",
+	"varying character": "string",
+	"nchar":             "string",
+	"nvarchar":          "string",
+	"text":              "string",
+	"clob":              "string",
+	"blob":              "string", // blob
+	"":                  "string",
+	"date":              "time.Time", // time
+	"datetime":          "time.Time",
+	"timestamp":         "time.Time",
+	"time":              "time.Time",
+	"real":              "float64", // float & decimal
+	"float":             "float64",
+	"double":            "float64",
+	"double precision":  "float64",
+	"decimal":           "float64",
+	"numeric":           "float64",
+}
+
 // Table represent a table in a database
 type Table struct {
 	Name          string
@@ -145,22 +194,33 @@ type Table struct {
 	Uk            []string
 	Fk            map[string]*ForeignKey
 	Columns       []*Column
+	Indexes       []*Index
 	ImportTimePkg bool
 }
 
 // Column reprsents a column for a table
 type Column struct {
-	Name string
-	Type string
-	Tag  *OrmTag
+	Name    string
+	Type    string
+	SQLType string // Declared type, e.g. varchar(255)
+	Tag     *OrmTag
 }
 
 // ForeignKey represents a foreign key column for a table
 type ForeignKey struct {
-	Name      string
-	RefSchema string
-	RefTable  string
-	RefColumn string
+	Name       string
+	Constraint string
+	RefSchema  string
+	RefTable   string
+	RefColumn  string
+}
+
+// Index represents an index of a table, other than the primary key
+type Index struct {
+	Name       string
+	Columns    []string
+	Unique     bool
+	Constraint bool // Backs a unique constraint, dropped with the constraint
 }
 
 // OrmTag contains IZIGo ORM tag information for a column
@@ -186,6 +246,17 @@ type OrmTag struct {
 	Comment     string //column comment
 }
 
+// addIndexColumn appends the column to the index of the table with the given name
+func (tb *Table) addIndexColumn(name, column string, unique, constraint bool) {
+	for _, index := range tb.Indexes {
+		if index.Name == name {
+			index.Columns = append(index.Columns, column)
+			return
+		}
+	}
+	tb.Indexes = append(tb.Indexes, &Index{Name: name, Columns: []string{column}, Unique: unique, Constraint: constraint})
+}
+
 // String returns the source code string for the Table struct
 func (tb *Table) String() string {
 	rv := fmt.Sprintf("type %s struct {\n", utils.CamelCase(tb.Name))
@@ -263,6 +334,54 @@ func (tag *OrmTag) String() string {
 	return fmt.Sprintf("`orm:\"%s\"`", strings.Join(ormOptions, ";"))
 }
 
+// ParseOrmTag reads the options of an ORM tag, e.g. column(id);auto
+func ParseOrmTag(tag string) *OrmTag {
+	ormTag := new(OrmTag)
+	for _, option := range strings.Split(tag, ";") {
+		option = strings.TrimSpace(option)
+		name, value := option, ""
+		if i := strings.Index(option, "("); i > 0 && strings.HasSuffix(option, ")") {
+			name, value = option[:i], option[i+1:len(option)-1]
+		}
+		switch name {
+		case "column":
+			ormTag.Column = value
+		case "auto":
+			ormTag.Auto = true
+		case "pk":
+			ormTag.Pk = true
+		case "null":
+			ormTag.Null = true
+		case "index":
+			ormTag.Index = true
+		case "unique":
+			ormTag.Unique = true
+		case "size":
+			ormTag.Size = value
+		case "digits":
+			ormTag.Digits = value
+		case "decimals":
+			ormTag.Decimals = value
+		case "auto_now":
+			ormTag.AutoNow = true
+		case "auto_now_add":
+			ormTag.AutoNowAdd = true
+		case "type":
+			ormTag.Type = value
+		case "default":
+			ormTag.Default = value
+		case "rel":
+			ormTag.RelFk = value == "fk"
+			ormTag.RelOne = value == "one"
+			ormTag.RelM2M = value == "m2m"
+		case "reverse":
+			ormTag.ReverseOne = value == "one"
+			ormTag.ReverseMany = value == "many"
+		}
+	}
+	return ormTag
+}
+
 func GenerateAppcode(driver, connStr, level, tables, currpath string) {
 	var mode byte
 	switch level {
@@ -286,7 +405,6 @@ func GenerateAppcode(driver, connStr, level, tables, currpath string) {
 	case "mysql":
 	case "postgres":
 	case "sqlite":
-		iziLogger.Log.Fatal("Generating app code from SQLite database is not supported yet.")
 	default:
 		iziLogger.Log.Fatal("Unknown database driver. Must be either \"mysql\", \"postgres\" or \"sqlite\"")
 	}
@@ -296,9 +414,9 @@ func GenerateAppcode(driver, connStr, level, tables, currpath string) {
 // Generate takes table, column and foreign key information from database connection
 // and generate corresponding golang source files
 func gen(dbms, connStr string, mode byte, selectedTableNames map[string]bool, apppath string) {
-	db, err := sql.Open(dbms, connStr)
+	db, err := sql.Open(SQLDriverName(dbms), connStr)
 	if err != nil {
-		iziLogger.Log.Fatalf("Could not connect to '%s' database using '%s': %s", dbms, connStr, err)
+		iziLogger.Log.Fatalf("Could not connect to '%s' database using '%s': %s", dbms, utils.MaskDSN(connStr), err)
 	}
 	defer db.Close()
 	if trans, ok := dbDriver[dbms]; ok {
@@ -368,7 +486,7 @@ func getTableObjects(tableNames []string, db *sql.DB, dbTransformer DbTransforme
 func (*MysqlDB) GetConstraints(db *sql.DB, table *Table, blackList map[string]bool) {
 	rows, err := db.Query(
 		`SELECT
-			c.constraint_type, u.column_name, u.referenced_table_schema, u.referenced_table_name, referenced_column_name, u.ordinal_position
+			c.constraint_type, u.column_name, u.referenced_table_schema, u.referenced_table_name, referenced_column_name, u.ordinal_position, c.constraint_name
 		FROM
 			information_schema.table_constraints c
 		INNER JOIN
@@ -380,8 +498,8 @@ func (*MysqlDB) GetConstraints(db *sql.DB, table *Table, blackList map[string]bo
 		iziLogger.Log.Fatal("Could not query INFORMATION_SCHEMA for PK/UK/FK information")
 	}
 	for rows.Next() {
-		var constraintTypeBytes, columnNameBytes, refTableSchemaBytes, refTableNameBytes, refColumnNameBytes, refOrdinalPosBytes []byte
-		if err := rows.Scan(&constraintTypeBytes, &columnNameBytes, &refTableSchemaBytes, &refTableNameBytes, &refColumnNameBytes, &refOrdinalPosBytes); err != nil {
+		var constraintTypeBytes, columnNameBytes, refTableSchemaBytes, refTableNameBytes, refColumnNameBytes, refOrdinalPosBytes, constraintNameBytes []byte
+		if err := rows.Scan(&constraintTypeBytes, &columnNameBytes, &refTableSchemaBytes, &refTableNameBytes, &refColumnNameBytes, &refOrdinalPosBytes, &constraintNameBytes); err != nil {
 			iziLogger.Log.Fatal("Could not read INFORMATION_SCHEMA for PK/UK/FK information")
 		}
 		constraintType, columnName, refTableSchema, refTableName, refColumnName, refOrdinalPos :=
@@ -401,6 +519,7 @@ func (*MysqlDB) GetConstraints(db *sql.DB, table *Table, blackList map[string]bo
 		} else if constraintType == "FOREIGN KEY" {
 			fk := new(ForeignKey)
 			fk.Name = columnName
+			fk.Constraint = string(constraintNameBytes)
 			fk.RefSchema = refTableSchema
 			fk.RefTable = refTableName
 			fk.RefColumn = refColumnName
@@ -438,6 +557,7 @@ func (mysqlDB *MysqlDB) GetColumns(db *sql.DB, table *Table, blackList map[strin
 		// create a column
 		col := new(Column)
 		col.Name = utils.CamelCase(colName)
+		col.SQLType = columnType
 		col.Type, err = mysqlDB.GetGoDataType(dataType)
 		if err != nil {
 			iziLogger.Log.Fatalf("%s", err)
@@ -464,6 +584,7 @@ func (mysqlDB *MysqlDB) GetColumns(db *sql.DB, table *Table, blackList map[strin
 			// check if the current column is a foreign key
 			if isFk && !isBl {
 				tag.RelFk = true
+				tag.Null = isNullable == "YES"
 				refStructName := fkCol.RefTable
 				col.Name = utils.CamelCase(colName)
 				col.Type = "*" + utils.CamelCase(refStructName)
@@ -514,6 +635,32 @@ func (mysqlDB *MysqlDB) GetColumns(db *sql.DB, table *Table, blackList map[strin
 	}
 }
 
+// GetIndexes retrieves the indexes of a table from information_schema
+func (*MysqlDB) GetIndexes(db *sql.DB, table *Table) {
+	rows, err := db.Query(
+		`SELECT
+			index_name, non_unique, column_name
+		FROM
+			information_schema.statistics
+		WHERE
+			table_schema = database() AND table_name = ? AND index_name <> 'PRIMARY'
+		ORDER BY
+			index_name, seq_in_index`,
+		table.Name)
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not query INFORMATION_SCHEMA for index information: %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, column string
+		var nonUnique int
+		if err := rows.Scan(&name, &nonUnique, &column); err != nil {
+			iziLogger.Log.Fatalf("Could not read INFORMATION_SCHEMA for index information: %s", err)
+		}
+		table.addIndexColumn(name, column, nonUnique == 0, false)
+	}
+}
+
 // GetGoDataType maps an SQL data type to Golang data type
 func (*MysqlDB) GetGoDataType(sqlType string) (string, error) {
 	if v, ok := typeMappingMysql[sqlType]; ok {
@@ -553,7 +700,8 @@ func (*PostgresDB) GetConstraints(db *sql.DB, table *Table, blackList map[string
 			cu.table_catalog AS referenced_table_catalog,
 			cu.table_name AS referenced_table_name,
 			cu.column_name AS referenced_column_name,
-			u.ordinal_position
+			u.ordinal_position,
+			c.constraint_name
 		FROM
 			information_schema.table_constraints c
 		INNER JOIN
@@ -571,8 +719,8 @@ func (*PostgresDB) GetConstraints(db *sql.DB, table *Table, blackList map[string
 	}
 
 	for rows.Next() {
-		var constraintTypeBytes, columnNameBytes, refTableSchemaBytes, refTableNameBytes, refColumnNameBytes, refOrdinalPosBytes []byte
-		if err := rows.Scan(&constraintTypeBytes, &columnNameBytes, &refTableSchemaBytes, &refTableNameBytes, &refColumnNameBytes, &refOrdinalPosBytes); err != nil {
+		var constraintTypeBytes, columnNameBytes, refTableSchemaBytes, refTableNameBytes, refColumnNameBytes, refOrdinalPosBytes, constraintNameBytes []byte
+		if err := rows.Scan(&constraintTypeBytes, &columnNameBytes, &refTableSchemaBytes, &refTableNameBytes, &refColumnNameBytes, &refOrdinalPosBytes, &constraintNameBytes); err != nil {
 			iziLogger.Log.Fatalf("Could not read INFORMATION_SCHEMA for PK/UK/FK information: %s", err)
 		}
 		constraintType, columnName, refTableSchema, refTableName, refColumnName, refOrdinalPos :=
@@ -592,6 +740,7 @@ func (*PostgresDB) GetConstraints(db *sql.DB, table *Table, blackList map[string
 		} else if constraintType == "FOREIGN KEY" {
 			fk := new(ForeignKey)
 			fk.Name = columnName
+			fk.Constraint = string(constraintNameBytes)
 			fk.RefSchema = refTableSchema
 			fk.RefTable = refTableName
 			fk.RefColumn = refColumnName
@@ -608,11 +757,11 @@ func (postgresDB *PostgresDB) GetColumns(db *sql.DB, table *Table, blackList map
 			column_name,
 			data_type,
 			data_type ||
-			CASE
-				WHEN data_type = 'character' THEN '('||character_maximum_length||')'
+			COALESCE(CASE
+				WHEN data_type IN ('character', 'character varying') THEN '('||character_maximum_length||')'
 				WHEN data_type = 'numeric' THEN '(' || numeric_precision || ',' || numeric_scale ||')'
 				ELSE ''
-			END AS column_type,
+			END, '') AS column_type,
 			is_nullable,
 			column_default,
 			'' AS extra
@@ -638,6 +787,7 @@ func (postgresDB *PostgresDB) GetColumns(db *sql.DB, table *Table, blackList map
 		// Create a column
 		col := new(Column)
 		col.Name = utils.CamelCase(colName)
+		col.SQLType = columnType
 		col.Type, err = postgresDB.GetGoDataType(dataType)
 		if err != nil {
 			iziLogger.Log.Fatalf("%s", err)
@@ -663,6 +813,7 @@ func (postgresDB *PostgresDB) GetColumns(db *sql.DB, table *Table, blackList map
 			// check if the current column is a foreign key
 			if isFk && !isBl {
 				tag.RelFk = true
+				tag.Null = isNullable == "YES"
 				refStructName := fkCol.RefTable
 				col.Name = utils.CamelCase(colName)
 				col.Type = "*" + utils.CamelCase(refStructName)
@@ -704,6 +855,37 @@ func (postgresDB *PostgresDB) GetColumns(db *sql.DB, table *Table, blackList map
 	}
 }
 
+// GetIndexes for PostgreSQL, from pg_index
+func (*PostgresDB) GetIndexes(db *sql.DB, table *Table) {
+	rows, err := db.Query(
+		`SELECT
+			i.relname, ix.indisunique, EXISTS (SELECT 1 FROM pg_constraint c WHERE c.conindid = ix.indexrelid), a.attname
+		FROM
+			pg_index ix
+		INNER JOIN pg_class t ON t.oid = ix.indrelid
+		INNER JOIN pg_class i ON i.oid = ix.indexrelid
+		INNER JOIN pg_namespace n ON n.oid = t.relnamespace
+		INNER JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, position) ON true
+		INNER JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+		WHERE
+			n.nspname = current_schema() AND t.relname = $1 AND NOT ix.indisprimary
+		ORDER BY
+			i.relname, k.position`,
+		table.Name)
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not query pg_index for index information: %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, column string
+		var unique, constraint bool
+		if err := rows.Scan(&name, &unique, &constraint, &column); err != nil {
+			iziLogger.Log.Fatalf("Could not read pg_index for index information: %s", err)
+		}
+		table.addIndexColumn(name, column, unique, constraint)
+	}
+}
+
 // GetGoDataType returns the Go type from the mapped Postgres type
 func (*PostgresDB) GetGoDataType(sqlType string) (string, error) {
 	if v, ok := typeMappingPostgres[sqlType]; ok {
@@ -712,6 +894,277 @@ func (*PostgresDB) GetGoDataType(sqlType string) (string, error) {
 	return "", fmt.Errorf("data type '%s' not found", sqlType)
 }
 
+// GetTableNames for SQLite
+func (*SqliteDB) GetTableNames(db *sql.DB) (tables []string) {
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not show tables: %s", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			iziLogger.Log.Fatalf("Could not show tables: %s", err)
+		}
+		tables = append(tables, name)
+	}
+	return
+}
+
+// sqliteColumn is a row of PRAGMA table_info
+type sqliteColumn struct {
+	Name      string
+	Type      string
+	NotNull   bool
+	Default   string
+	PkOrdinal int
+}
+
+// sqliteTableInfo reads the columns of a table from PRAGMA table_info
+func sqliteTableInfo(db *sql.DB, table string) (columns []sqliteColumn) {
+	rows, err := db.Query("PRAGMA table_info(" + sqliteQuote(table) + ")")
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not query PRAGMA table_info for column information: %s", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid                     int
+			col                     sqliteColumn
+			typeBytes, defaultBytes []byte
+		)
+		if err := rows.Scan(&cid, &col.Name, &typeBytes, &col.NotNull, &defaultBytes, &col.PkOrdinal); err != nil {
+			iziLogger.Log.Fatalf("Could not read PRAGMA table_info for column information: %s", err)
+		}
+		col.Type, col.Default = string(typeBytes), string(defaultBytes)
+		columns = append(columns, col)
+	}
+	return
+}
+
+// GetConstraints for SQLite, from PRAGMA table_info, index_list and foreign_key_list
+func (*SqliteDB) GetConstraints(db *sql.DB, table *Table, blackList map[string]bool) {
+	pks := 0
+	for _, col := range sqliteTableInfo(db, table.Name) {
+		if col.PkOrdinal > 0 {
+			pks++
+			table.Pk = col.Name
+		}
+	}
+	if pks > 1 {
+		table.Pk = ""
+		// add table to blacklist so that other struct will not reference it, because we are not
+		// registering blacklisted tables
+		blackList[table.Name] = true
+	}
+
+	// unique keys are the unique indexes on a single column
+	indexRows, err := db.Query("PRAGMA index_list(" + sqliteQuote(table.Name) + ")")
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not query PRAGMA index_list for UK information: %s", err)
+	}
+	var uniqueIndexes []string
+	for indexRows.Next() {
+		cols, err := indexRows.Columns()
+		if err != nil {
+			iziLogger.Log.Fatalf("Could not read PRAGMA index_list for UK information: %s", err)
+		}
+		// seq, name, unique, origin, partial; older SQLite versions only return the first three
+		values := make([]sql.RawBytes, len(cols))
+		dest := make([]interface{}, len(cols))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := indexRows.Scan(dest...); err != nil {
+			iziLogger.Log.Fatalf("Could not read PRAGMA index_list for UK information: %s", err)
+		}
+		if string(values[2]) == "1" && (len(values) < 4 || string(values[3]) != "pk") {
+			uniqueIndexes = append(uniqueIndexes, string(values[1]))
+		}
+	}
+	indexRows.Close()
+
+	for _, index := range uniqueIndexes {
+		rows, err := db.Query("PRAGMA index_info(" + sqliteQuote(index) + ")")
+		if err != nil {
+			iziLogger.Log.Fatalf("Could not query PRAGMA index_info for UK information: %s", err)
+		}
+		var columns []string
+		for rows.Next() {
+			var seqno, cid int
+			var name []byte
+			if err := rows.Scan(&seqno, &cid, &name); err != nil {
+				iziLogger.Log.Fatalf("Could not read PRAGMA index_info for UK information: %s", err)
+			}
+			columns = append(columns, string(name))
+		}
+		rows.Close()
+		if len(columns) == 1 {
+			table.Uk = append(table.Uk, columns[0])
+		}
+	}
+
+	fkRows, err := db.Query("PRAGMA foreign_key_list(" + sqliteQuote(table.Name) + ")")
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not query PRAGMA foreign_key_list for FK information: %s", err)
+	}
+	defer fkRows.Close()
+	for fkRows.Next() {
+		var (
+			id, seq                                    int
+			refTable, from, to, onUpdate, onDelete, mt []byte
+		)
+		if err := fkRows.Scan(&id, &seq, &refTable, &from, &to, &onUpdate, &onDelete, &mt); err != nil {
+			iziLogger.Log.Fatalf("Could not read PRAGMA foreign_key_list for FK information: %s", err)
+		}
+		fk := new(ForeignKey)
+		fk.Name = string(from)
+		fk.RefTable = string(refTable)
+		fk.RefColumn = string(to)
+		table.Fk[fk.Name] = fk
+	}
+}
+
+// GetColumns for SQLite
+func (sqliteDB *SqliteDB) GetColumns(db *sql.DB, table *Table, blackList map[string]bool) {
+	for _, def := range sqliteTableInfo(db, table.Name) {
+		colName := def.Name
+		// varchar(255) => varchar, decimal(10,2) => decimal
+		columnType := strings.ToLower(strings.Replace(def.Type, " ", "", -1))
+		dataType := strings.ToLower(strings.TrimSpace(def.Type))
+		if i := strings.Index(dataType, "("); i >= 0 {
+			dataType = strings.TrimSpace(dataType[:i])
+		}
+
+		// Create a column
+		col := new(Column)
+		col.Name = utils.CamelCase(colName)
+		col.SQLType = strings.ToLower(strings.TrimSpace(def.Type))
+		col.Type, _ = sqliteDB.GetGoDataType(dataType)
+
+		// Tag info
+		tag := new(OrmTag)
+		tag.Column = colName
+		if table.Pk == colName {
+			col.Name = "Id"
+			col.Type = "int"
+			// INTEGER PRIMARY KEY columns are aliases of the auto-incremented rowid
+			if dataType == "integer" {
+				tag.Auto = true
+			} else {
+				tag.Pk = true
+			}
+		} else {
+			fkCol, isFk := table.Fk[colName]
+			isBl := false
+			if isFk {
+				_, isBl = blackList[fkCol.RefTable]
+			}
+			// check if the current column is a foreign key
+			if isFk && !isBl {
+				tag.RelFk = true
+				tag.Null = !def.NotNull
+				refStructName := fkCol.RefTable
+				col.Name = utils.CamelCase(colName)
+				col.Type = "*" + utils.CamelCase(refStructName)
+			} else {
+				// if the name of column is Id, and it's not primary key
+				if colName == "id" {
+					col.Name = "Id_RENAME"
+				}
+				if !def.NotNull {
+					tag.Null = true
+				}
+				if isSQLStringType(dataType) && strings.Contains(columnType, "(") {
+					tag.Size = extractColSize(columnType)
+				}
+				if isSQLTemporalType(dataType) {
+					tag.Type = dataType
+					//check auto_now_add
+					if strings.ToUpper(def.Default) == "CURRENT_TIMESTAMP" {
+						tag.AutoNowAdd = true
+					}
+					// need to import time package
+					table.ImportTimePkg = true
+				}
+				if isSQLDecimal(dataType) && strings.Contains(columnType, ",") {
+					tag.Digits, tag.Decimals = extractDecimal(columnType)
+				}
+			}
+		}
+		col.Tag = tag
+		table.Columns = append(table.Columns, col)
+	}
+}
+
+// GetIndexes for SQLite, from PRAGMA index_list and index_info
+func (*SqliteDB) GetIndexes(db *sql.DB, table *Table) {
+	rows, err := db.Query("SELECT name, \"unique\", origin FROM pragma_index_list(?) WHERE origin <> 'pk' ORDER BY name", table.Name)
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not query PRAGMA index_list for index information: %s", err)
+	}
+	var indexes []*Index
+	for rows.Next() {
+		var origin string
+		index := new(Index)
+		if err := rows.Scan(&index.Name, &index.Unique, &origin); err != nil {
+			iziLogger.Log.Fatalf("Could not read PRAGMA index_list for index information: %s", err)
+		}
+		// UNIQUE column constraints create the sqlite_autoindex indexes
+		index.Constraint = origin == "u"
+		indexes = append(indexes, index)
+	}
+	rows.Close()
+
+	for _, index := range indexes {
+		columns, err := db.Query("SELECT name FROM pragma_index_info(?) ORDER BY seqno", index.Name)
+		if err != nil {
+			iziLogger.Log.Fatalf("Could not query PRAGMA index_info for index information: %s", err)
+		}
+		for columns.Next() {
+			var column string
+			if err := columns.Scan(&column); err != nil {
+				iziLogger.Log.Fatalf("Could not read PRAGMA index_info for index information: %s", err)
+			}
+			index.Columns = append(index.Columns, column)
+		}
+		columns.Close()
+		table.Indexes = append(table.Indexes, index)
+	}
+}
+
+// GetGoDataType returns the Go type from the SQLite declared type,
+// following the SQLite type affinity rules for the unknown ones
+func (*SqliteDB) GetGoDataType(sqlType string) (string, error) {
+	if v, ok := typeMappingSqlite[sqlType]; ok {
+		return v, nil
+	}
+	switch {
+	case strings.Contains(sqlType, "int"):
+		return "int64", nil
+	case strings.Contains(sqlType, "char"), strings.Contains(sqlType, "clob"), strings.Contains(sqlType, "text"):
+		return "string", nil
+	case strings.Contains(sqlType, "blob"):
+		return "string", nil
+	}
+	return "float64", nil
+}
+
+// sqliteQuote quotes an identifier for the PRAGMA statements
+func sqliteQuote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// SQLDriverName returns the name under which the driver is registered in database/sql
+func SQLDriverName(driver string) string {
+	if driver == "sqlite" {
+		return "sqlite3"
+	}
+	return driver
+}
+
 // deleteAndRecreatePaths removes several directories completely
 func createPaths(mode byte, paths *MvcPath) {
 	if (mode & OModel) == OModel {
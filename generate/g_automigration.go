@@ -0,0 +1,856 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"database/sql"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/izi-global/izi/logger"
+	"github.com/izi-global/izi/utils"
+)
+
+// Tables of izi itself, never part of the models
+var migrationTables = map[string]bool{"migrations": true, "migrations_lock": true}
+
+var (
+	intWidthRegex = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|integer|bigint)\(\d+\)`)
+	spacesRegex   = regexp.MustCompile(`\s+`)
+)
+
+// sqlTypeSynonyms maps the names of the same type to a single one
+var sqlTypeSynonyms = map[string]string{
+	"integer":                     "int",
+	"int4":                        "int",
+	"serial":                      "int",
+	"int2":                        "smallint",
+	"int8":                        "bigint",
+	"bigserial":                   "bigint",
+	"bool":                        "boolean",
+	"character varying":           "varchar",
+	"character":                   "char",
+	"timestamp without time zone": "timestamp",
+	"timestamptz":                 "timestamp with time zone",
+	"double precision":            "double",
+	"float8":                      "double",
+	"float4":                      "real",
+	"decimal":                     "numeric",
+}
+
+// Types of the sql package holding nullable values
+var sqlNullTypes = map[string]string{
+	"sql.NullString":  "string",
+	"sql.NullInt64":   "int64",
+	"sql.NullFloat64": "float64",
+	"sql.NullBool":    "bool",
+}
+
+// schemaChange is a change of the schema and the statements applying and
+// reverting it. The statements of the changes needing a review are commented out.
+type schemaChange struct {
+	description string
+	note        string // Warning about the Up statements
+	review      string
+	downReview  string // Review of the Down statements only
+	up, down    []string
+}
+
+// code returns the m.SQL calls running the statements, the Down ones reverting the change.
+func (c *schemaChange) code(statements []string, down bool) string {
+	var b strings.Builder
+	if down {
+		b.WriteString("// Revert: " + c.description + "\n")
+	} else {
+		b.WriteString("// " + c.description + "\n")
+		if c.note != "" {
+			b.WriteString("// NOTE: " + c.note + "\n")
+		}
+	}
+	review := c.review
+	if down && c.downReview != "" {
+		review = c.downReview
+	}
+	if review != "" {
+		b.WriteString("// REVIEW: " + review + "\n")
+	}
+	for _, s := range statements {
+		if review != "" {
+			b.WriteString("// ")
+		}
+		b.WriteString("m.SQL(" + strconv.Quote(s) + ")\n")
+	}
+	return b.String()
+}
+
+// GenerateAutoMigration compares the models package with the database and
+// returns the Up and Down code of the migration turning the schema of the
+// database into the one of the models. tables limits the comparison to the
+// listed tables. Changes losing data and changes the database cannot run
+// are commented out for review.
+func GenerateAutoMigration(driver, connStr, tables, currpath string) (upsql, downsql string) {
+	selected := make(map[string]bool)
+	for _, t := range strings.Split(tables, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			selected[t] = true
+		}
+	}
+	included := func(table string) bool {
+		return !migrationTables[table] && (len(selected) == 0 || selected[table])
+	}
+
+	m := newDBDriver(driver)
+	var models []*Table
+	for _, t := range parseModels(path.Join(currpath, "models")) {
+		if included(t.Name) {
+			for _, col := range t.Columns {
+				col.SQLType = m.ColumnType(col)
+			}
+			models = append(models, t)
+		}
+	}
+
+	db, err := sql.Open(SQLDriverName(driver), connStr)
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not connect to '%s' database using '%s': %s", driver, utils.MaskDSN(connStr), err)
+	}
+	defer db.Close()
+	trans, ok := dbDriver[driver]
+	if !ok {
+		iziLogger.Log.Fatalf("Generating migrations from '%s' database is not supported yet.", driver)
+	}
+	iziLogger.Log.Info("Analyzing database tables...")
+	var tableNames []string
+	for _, name := range trans.GetTableNames(db) {
+		if included(name) {
+			tableNames = append(tableNames, name)
+		}
+	}
+	existing := make(map[string]*Table)
+	for _, t := range getTableObjects(tableNames, db, trans) {
+		trans.GetIndexes(db, t)
+		existing[t.Name] = t
+	}
+
+	changes := diffSchema(m, models, existing, tableNames)
+	reviews := 0
+	for _, c := range changes {
+		upsql += c.code(c.up, false)
+		if c.review != "" {
+			reviews++
+		}
+	}
+	for i := len(changes) - 1; i >= 0; i-- {
+		downsql += changes[i].code(changes[i].down, true)
+	}
+	if reviews > 0 {
+		iziLogger.Log.Warnf("%d changes are commented out in the migration, review them before running it", reviews)
+	}
+	return
+}
+
+// diffSchema returns the changes turning the existing tables into the models.
+func diffSchema(m DBDriver, models []*Table, existing map[string]*Table, tableNames []string) []*schemaChange {
+	var changes []*schemaChange
+	add := func(c *schemaChange) {
+		changes = append(changes, c)
+	}
+
+	modelTables := make(map[string]*Table)
+	var created []string
+	for _, t := range models {
+		modelTables[t.Name] = t
+		if existing[t.Name] == nil {
+			created = append(created, t.Name)
+		}
+	}
+
+	// New tables, after the tables they reference
+	for _, name := range utils.SortByDependencies(created, foreignKeyDependencies(modelTables)) {
+		t := modelTables[name]
+		add(&schemaChange{
+			description: "Create table " + name,
+			up:          append([]string{m.CreateTable(t)}, createIndexes(m, t, t.Indexes)...),
+			down:        []string{m.DropTable(name)},
+		})
+	}
+
+	for _, t := range models {
+		if current := existing[t.Name]; current != nil {
+			changes = append(changes, diffTable(m, t, current)...)
+		}
+	}
+
+	// Dropped tables, before the tables they reference
+	var dropped []string
+	for _, name := range tableNames {
+		if modelTables[name] == nil {
+			dropped = append(dropped, name)
+		}
+	}
+	dropped = utils.SortByDependencies(dropped, foreignKeyDependencies(existing))
+	for i := len(dropped) - 1; i >= 0; i-- {
+		t := existing[dropped[i]]
+		add(&schemaChange{
+			description: "Drop table " + t.Name,
+			review:      "drops the table and its data, no model maps it",
+			up:          []string{m.DropTable(t.Name)},
+			down:        append([]string{m.CreateTable(t)}, createIndexes(m, t, t.Indexes)...),
+		})
+	}
+	return changes
+}
+
+// diffTable returns the changes turning the existing table into the model.
+func diffTable(m DBDriver, model, current *Table) []*schemaChange {
+	var changes []*schemaChange
+	add := func(c *schemaChange) {
+		changes = append(changes, c)
+	}
+	table := model.Name
+	modelColumns := columnsByName(model)
+	currentColumns := columnsByName(current)
+
+	// Foreign keys removed or pointing elsewhere
+	droppedFks := make(map[string]bool)
+	for _, col := range current.Columns {
+		fk, ok := current.Fk[col.Tag.Column]
+		if !ok {
+			continue
+		}
+		if want, ok := model.Fk[fk.Name]; ok && want.RefTable == fk.RefTable && want.RefColumn == fk.RefColumn {
+			continue
+		}
+		droppedFks[fk.Name] = true
+		c := &schemaChange{
+			description: fmt.Sprintf("Drop foreign key %s.%s referencing %s", table, fk.Name, fk.RefTable),
+			up:          nonEmpty(m.DropForeignKey(table, fk)),
+			down:        nonEmpty(m.AddForeignKey(table, fk)),
+		}
+		if len(c.up) == 0 {
+			c.review = "the database cannot drop foreign keys, rebuild the table"
+		}
+		add(c)
+	}
+
+	// Indexes removed
+	modelIndexes := indexesBySignature(model.Indexes)
+	currentIndexes := indexesBySignature(current.Indexes)
+	for _, index := range current.Indexes {
+		if modelIndexes[indexSignature(index)] != nil || isForeignKeyIndex(current, index) {
+			continue
+		}
+		c := &schemaChange{
+			description: fmt.Sprintf("Drop index %s of %s", index.Name, table),
+			up:          nonEmpty(m.DropIndex(table, index)),
+			down:        nonEmpty(m.CreateIndex(table, index)),
+		}
+		if len(c.up) == 0 {
+			c.review = "the database cannot drop the index of a UNIQUE constraint, rebuild the table"
+		}
+		add(c)
+	}
+
+	// Columns added or changed
+	for _, col := range model.Columns {
+		name := col.Tag.Column
+		existing, ok := currentColumns[name]
+		if !ok {
+			c := &schemaChange{
+				description: fmt.Sprintf("Add column %s.%s", table, name),
+				note:        downRequirementNote(m, OpDropColumn),
+				up:          []string{m.AddColumn(table, col, model.Fk[name])},
+				down:        []string{m.DropColumn(table, name)},
+			}
+			if !col.Tag.Null && col.Tag.Default == "" {
+				c.note = joinNotes("NOT NULL without a default, fails when the table has rows", c.note)
+			}
+			add(c)
+			continue
+		}
+		if name == model.Pk || name == current.Pk || !columnChanged(col, existing) {
+			continue
+		}
+		c := &schemaChange{
+			description: fmt.Sprintf("Change column %s.%s from %s to %s", table, name, columnSummary(existing), columnSummary(col)),
+			up:          m.AlterColumn(table, col),
+			down:        m.AlterColumn(table, existing),
+		}
+		switch {
+		case !typeWidened(existing.SQLType, col.SQLType):
+			c.review = "existing values may not convert to the new type"
+		case existing.Tag.Null && !col.Tag.Null:
+			c.review = "fails when the column holds NULL values"
+		default:
+			// Widening keeps the values, narrowing them back may not
+			c.downReview = "the values written since may not convert to the former type"
+		}
+		if len(c.up) == 0 {
+			c.review = "the database cannot alter columns, rebuild the table"
+		}
+		add(c)
+	}
+
+	// Indexes added
+	for _, index := range model.Indexes {
+		if currentIndexes[indexSignature(index)] == nil {
+			add(&schemaChange{
+				description: fmt.Sprintf("Create index %s of %s", index.Name, table),
+				up:          []string{m.CreateIndex(table, index)},
+				down:        []string{m.DropIndex(table, index)},
+			})
+		}
+	}
+
+	// Foreign keys added, SQLite declares those of the new columns with them
+	for _, col := range model.Columns {
+		fk, ok := model.Fk[col.Tag.Column]
+		if !ok {
+			continue
+		}
+		if _, ok := current.Fk[fk.Name]; ok && !droppedFks[fk.Name] {
+			continue
+		}
+		_, isNew := currentColumns[fk.Name]
+		isNew = !isNew
+		c := &schemaChange{
+			description: fmt.Sprintf("Add foreign key %s.%s referencing %s", table, fk.Name, fk.RefTable),
+			up:          nonEmpty(m.AddForeignKey(table, fk)),
+			down:        nonEmpty(m.DropForeignKey(table, fk)),
+		}
+		if len(c.up) == 0 && isNew {
+			continue
+		}
+		if len(c.up) == 0 {
+			c.review = "the database cannot add foreign keys to existing columns, rebuild the table"
+		}
+		add(c)
+	}
+
+	// Columns removed
+	for _, col := range current.Columns {
+		name := col.Tag.Column
+		if _, ok := modelColumns[name]; ok {
+			continue
+		}
+		var fk *ForeignKey
+		if !droppedFks[name] {
+			fk = current.Fk[name]
+		}
+		add(&schemaChange{
+			description: fmt.Sprintf("Drop column %s.%s", table, name),
+			note:        requirementNote(m, OpDropColumn),
+			review:      "drops the column and its data, no field maps it",
+			up:          []string{m.DropColumn(table, name)},
+			down:        []string{m.AddColumn(table, col, fk)},
+		})
+	}
+	return changes
+}
+
+// columnChanged reports whether the type or the nullability of the column differ.
+func columnChanged(model, current *Column) bool {
+	if current.SQLType != "" && normalizeSQLType(model.SQLType) != normalizeSQLType(current.SQLType) {
+		return true
+	}
+	return model.Tag.Null != current.Tag.Null
+}
+
+// typeWidened reports whether every value of the from type converts to the
+// to type: a larger size of the same type, or a larger type of its family.
+func typeWidened(from, to string) bool {
+	from, to = normalizeSQLType(from), normalizeSQLType(to)
+	if from == to {
+		return true
+	}
+	fromBase, fromSize := splitSQLType(from)
+	toBase, toSize := splitSQLType(to)
+	if fromBase != toBase {
+		fromRank, toRank := sqlTypeRank(fromBase), sqlTypeRank(toBase)
+		return fromRank >= 0 && toRank >= 0 && fromRank/100 == toRank/100 && fromRank < toRank && toSize == nil
+	}
+	if toSize == nil {
+		return true
+	}
+	if fromSize == nil || len(fromSize) != len(toSize) {
+		return false
+	}
+	// numeric(precision, scale) keeps its integer digits and its decimals
+	for i := range fromSize {
+		if toSize[i] < fromSize[i] {
+			return false
+		}
+	}
+	return len(fromSize) == 1 || toSize[0]-toSize[1] >= fromSize[0]-fromSize[1]
+}
+
+// Families of types ordered by the values they hold
+var widerSQLTypes = [][]string{
+	{"tinyint", "smallint", "mediumint", "int", "bigint"},
+	{"real", "double"},
+	{"varchar", "text", "mediumtext", "longtext"},
+}
+
+// sqlTypeRank returns 100 times the family of the type plus its rank in
+// the family, -1 for the types of no family.
+func sqlTypeRank(base string) int {
+	for family, types := range widerSQLTypes {
+		for rank, t := range types {
+			if t == base {
+				return family*100 + rank
+			}
+		}
+	}
+	return -1
+}
+
+// splitSQLType returns the base of a normalized type and its sizes, e.g.
+// numeric and [10 2] for numeric(10,2).
+func splitSQLType(t string) (string, []int) {
+	i := strings.Index(t, "(")
+	if i < 0 || !strings.HasSuffix(t, ")") {
+		return t, nil
+	}
+	var sizes []int
+	for _, p := range strings.Split(t[i+1:len(t)-1], ",") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return t, nil
+		}
+		sizes = append(sizes, n)
+	}
+	return t[:i], sizes
+}
+
+func columnSummary(col *Column) string {
+	if col.Tag.Null {
+		return col.SQLType + " NULL"
+	}
+	return col.SQLType + " NOT NULL"
+}
+
+// normalizeSQLType returns the canonical name of a declared type, so the
+// types of the models compare with the introspected ones.
+func normalizeSQLType(t string) string {
+	t = spacesRegex.ReplaceAllString(strings.ToLower(strings.TrimSpace(t)), " ")
+	// MySQL keeps the display width of tinyint(1), the booleans
+	if t != "tinyint(1)" {
+		t = intWidthRegex.ReplaceAllString(t, "$1")
+	}
+	base, params := t, ""
+	if i := strings.Index(t, "("); i >= 0 {
+		base, params = strings.TrimSpace(t[:i]), strings.Replace(t[i:], " ", "", -1)
+	}
+	if synonym, ok := sqlTypeSynonyms[base]; ok {
+		base = synonym
+	}
+	return base + params
+}
+
+func columnsByName(table *Table) map[string]*Column {
+	columns := make(map[string]*Column)
+	for _, col := range table.Columns {
+		columns[col.Tag.Column] = col
+	}
+	return columns
+}
+
+func indexSignature(index *Index) string {
+	return fmt.Sprintf("%t:%s", index.Unique, strings.Join(index.Columns, ","))
+}
+
+func indexesBySignature(indexes []*Index) map[string]*Index {
+	bySignature := make(map[string]*Index)
+	for _, index := range indexes {
+		bySignature[indexSignature(index)] = index
+	}
+	return bySignature
+}
+
+// isForeignKeyIndex reports whether MySQL created the index for a foreign key.
+func isForeignKeyIndex(table *Table, index *Index) bool {
+	if index.Unique || len(index.Columns) != 1 {
+		return false
+	}
+	_, ok := table.Fk[index.Columns[0]]
+	return ok
+}
+
+func createIndexes(m DBDriver, table *Table, indexes []*Index) []string {
+	var statements []string
+	for _, index := range indexes {
+		if index.Constraint && strings.HasPrefix(index.Name, "sqlite_autoindex_") {
+			// Recreated as a regular unique index
+			index = &Index{Name: "uniq_" + table.Name + "_" + strings.Join(index.Columns, "_"), Columns: index.Columns, Unique: true}
+		}
+		statements = append(statements, m.CreateIndex(table.Name, index))
+	}
+	return statements
+}
+
+func foreignKeyDependencies(tables map[string]*Table) map[string][]string {
+	deps := make(map[string][]string)
+	for name, t := range tables {
+		for _, fk := range t.Fk {
+			if fk.RefTable != name {
+				deps[name] = append(deps[name], fk.RefTable)
+			}
+		}
+	}
+	return deps
+}
+
+func nonEmpty(statement string) []string {
+	if statement == "" {
+		return nil
+	}
+	return []string{statement}
+}
+
+// model is a struct of the models package registered with the ORM.
+type model struct {
+	name    string
+	table   string
+	fields  *ast.FieldList
+	indexes [][]string
+	uniques [][]string
+}
+
+// parseModels reads the tables of the structs registered with
+// orm.RegisterModel in the models package, from their orm tags.
+func parseModels(dir string) []*Table {
+	if !utils.IsExist(dir) {
+		iziLogger.Log.Fatalf("Models directory '%s' does not exist", dir)
+	}
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		iziLogger.Log.Fatalf("Could not parse the models: %s", err)
+	}
+
+	structs := make(map[string]*ast.StructType)
+	methods := make(map[string]map[string]*ast.FuncDecl)
+	prefixes := make(map[string]string)
+	var registered []string
+	var pkgNames []string
+	for name := range pkgs {
+		pkgNames = append(pkgNames, name)
+	}
+	sort.Strings(pkgNames)
+	for _, pkgName := range pkgNames {
+		var files []string
+		for name := range pkgs[pkgName].Files {
+			files = append(files, name)
+		}
+		sort.Strings(files)
+		for _, name := range files {
+			ast.Inspect(pkgs[pkgName].Files[name], func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.TypeSpec:
+					if s, ok := n.Type.(*ast.StructType); ok {
+						structs[n.Name.Name] = s
+					}
+				case *ast.FuncDecl:
+					if recv := receiverName(n); recv != "" {
+						if methods[recv] == nil {
+							methods[recv] = make(map[string]*ast.FuncDecl)
+						}
+						methods[recv][n.Name.Name] = n
+					}
+				case *ast.CallExpr:
+					sel, ok := n.Fun.(*ast.SelectorExpr)
+					if !ok || sel.Sel.Name != "RegisterModel" && sel.Sel.Name != "RegisterModelWithPrefix" {
+						return true
+					}
+					args, prefix := n.Args, ""
+					if sel.Sel.Name == "RegisterModelWithPrefix" && len(args) > 0 {
+						prefix, args = stringLiteral(args[0]), args[1:]
+					}
+					for _, arg := range args {
+						if name := modelName(arg); name != "" {
+							registered = append(registered, name)
+							prefixes[name] = prefix
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+	if len(registered) == 0 {
+		iziLogger.Log.Fatalf("No model is registered with orm.RegisterModel in '%s'", dir)
+	}
+
+	models := make(map[string]*model)
+	var ordered []*model
+	for _, name := range registered {
+		s, ok := structs[name]
+		if !ok {
+			iziLogger.Log.Warnf("Model '%s' is not declared in the models package, skipping it", name)
+			continue
+		}
+		md := &model{name: name, table: utils.SnakeString(name), fields: s.Fields}
+		if f := methods[name]["TableName"]; f != nil {
+			if table := returnedString(f); table != "" {
+				md.table = table
+			}
+		}
+		md.table = prefixes[name] + md.table
+		if f := methods[name]["TableIndex"]; f != nil {
+			md.indexes = returnedStringLists(f)
+		}
+		if f := methods[name]["TableUnique"]; f != nil {
+			md.uniques = returnedStringLists(f)
+		}
+		models[name] = md
+		ordered = append(ordered, md)
+	}
+
+	tables := make(map[string]*Table)
+	for _, md := range ordered {
+		tables[md.name] = md.tableWithoutRelations()
+	}
+	// Foreign keys reference the primary key of the related model
+	var result []*Table
+	for _, md := range ordered {
+		table := tables[md.name]
+		for _, col := range table.Columns {
+			if !col.Tag.RelFk && !col.Tag.RelOne {
+				continue
+			}
+			ref, ok := tables[strings.TrimPrefix(col.Type, "*")]
+			if !ok || ref.Pk == "" {
+				iziLogger.Log.Fatalf("Field %s.%s relates to '%s', which is not a registered model with a primary key", md.name, col.Name, col.Type)
+			}
+			pk := columnsByName(ref)[ref.Pk]
+			col.Type = pk.Type
+			table.Fk[col.Tag.Column] = &ForeignKey{Name: col.Tag.Column, RefTable: ref.Name, RefColumn: ref.Pk}
+		}
+		md.addIndexes(table)
+		result = append(result, table)
+	}
+	return result
+}
+
+// tableWithoutRelations returns the table of the model, the types of the
+// foreign keys being the related structs.
+func (md *model) tableWithoutRelations() *Table {
+	table := &Table{Name: md.table, Fk: make(map[string]*ForeignKey)}
+	var id *Column
+	for _, field := range md.fields.List {
+		if len(field.Names) == 0 || !field.Names[0].IsExported() {
+			continue
+		}
+		ormTag := ""
+		if field.Tag != nil {
+			tag, _ := strconv.Unquote(field.Tag.Value)
+			ormTag = reflect.StructTag(tag).Get("orm")
+		}
+		if ormTag == "-" {
+			continue
+		}
+		tag := ParseOrmTag(ormTag)
+		if tag.ReverseOne || tag.ReverseMany || tag.RelM2M {
+			continue
+		}
+		typ := typeString(field.Type)
+		if base, ok := sqlNullTypes[typ]; ok {
+			typ, tag.Null = base, true
+		} else if strings.HasPrefix(typ, "*") && !tag.RelFk && !tag.RelOne {
+			typ, tag.Null = typ[1:], true
+		}
+		if strings.HasPrefix(typ, "[]") && typ != "[]byte" {
+			continue
+		}
+		for _, name := range field.Names {
+			col := &Column{Name: name.Name, Type: typ, Tag: copyOrmTag(tag)}
+			if col.Tag.Column == "" {
+				col.Tag.Column = utils.SnakeString(name.Name)
+				if tag.RelFk || tag.RelOne {
+					col.Tag.Column += "_id"
+				}
+			}
+			if tag.Pk || tag.Auto {
+				table.Pk = col.Tag.Column
+			}
+			if name.Name == "Id" && strings.Contains(typ, "int") {
+				id = col
+			}
+			table.Columns = append(table.Columns, col)
+		}
+	}
+	// The ORM uses an integer Id field as auto incremented primary key
+	if table.Pk == "" && id != nil {
+		table.Pk, id.Tag.Auto = id.Tag.Column, true
+	}
+	return table
+}
+
+// addIndexes adds the indexes of the index and unique tags, TableIndex and TableUnique.
+func (md *model) addIndexes(table *Table) {
+	add := func(columns []string, unique bool) {
+		prefix := "idx_"
+		if unique {
+			prefix = "uniq_"
+		}
+		table.Indexes = append(table.Indexes, &Index{
+			Name:    prefix + table.Name + "_" + strings.Join(columns, "_"),
+			Columns: columns,
+			Unique:  unique,
+		})
+	}
+	for _, col := range table.Columns {
+		if col.Tag.Column == table.Pk {
+			continue
+		}
+		if col.Tag.Unique {
+			add([]string{col.Tag.Column}, true)
+		} else if col.Tag.Index {
+			add([]string{col.Tag.Column}, false)
+		}
+	}
+	// The fields are named by their Go name or their column
+	columnOf := make(map[string]string)
+	for _, col := range table.Columns {
+		columnOf[col.Name] = col.Tag.Column
+		columnOf[col.Tag.Column] = col.Tag.Column
+	}
+	columns := func(fields []string) []string {
+		var names []string
+		for _, f := range fields {
+			if c, ok := columnOf[f]; ok {
+				names = append(names, c)
+			} else {
+				names = append(names, f)
+			}
+		}
+		return names
+	}
+	for _, fields := range md.indexes {
+		add(columns(fields), false)
+	}
+	for _, fields := range md.uniques {
+		add(columns(fields), true)
+	}
+}
+
+func copyOrmTag(tag *OrmTag) *OrmTag {
+	c := *tag
+	return &c
+}
+
+// typeString returns the source of a type expression, e.g. *time.Time
+func typeString(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return "*" + typeString(t.X)
+	case *ast.SelectorExpr:
+		return typeString(t.X) + "." + t.Sel.Name
+	case *ast.ArrayType:
+		return "[]" + typeString(t.Elt)
+	}
+	return ""
+}
+
+// receiverName returns the name of the type of the method receiver
+func receiverName(f *ast.FuncDecl) string {
+	if f.Recv == nil || len(f.Recv.List) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(typeString(f.Recv.List[0].Type), "*")
+}
+
+// modelName returns the struct name of new(Model) and &Model{}
+func modelName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.CallExpr:
+		if fun, ok := e.Fun.(*ast.Ident); ok && fun.Name == "new" && len(e.Args) == 1 {
+			return typeString(e.Args[0])
+		}
+	case *ast.UnaryExpr:
+		if lit, ok := e.X.(*ast.CompositeLit); ok && e.Op == token.AND {
+			return typeString(lit.Type)
+		}
+	}
+	return ""
+}
+
+func stringLiteral(expr ast.Expr) string {
+	if lit, ok := expr.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+		s, _ := strconv.Unquote(lit.Value)
+		return s
+	}
+	return ""
+}
+
+// returnedString returns the string literal returned by a method such as TableName
+func returnedString(f *ast.FuncDecl) string {
+	if f.Body == nil {
+		return ""
+	}
+	for _, stmt := range f.Body.List {
+		if ret, ok := stmt.(*ast.ReturnStmt); ok && len(ret.Results) == 1 {
+			return stringLiteral(ret.Results[0])
+		}
+	}
+	return ""
+}
+
+// returnedStringLists returns the [][]string literal returned by TableIndex and TableUnique
+func returnedStringLists(f *ast.FuncDecl) [][]string {
+	if f.Body == nil {
+		return nil
+	}
+	var lists [][]string
+	for _, stmt := range f.Body.List {
+		ret, ok := stmt.(*ast.ReturnStmt)
+		if !ok || len(ret.Results) != 1 {
+			continue
+		}
+		outer, ok := ret.Results[0].(*ast.CompositeLit)
+		if !ok {
+			continue
+		}
+		for _, elt := range outer.Elts {
+			inner, ok := elt.(*ast.CompositeLit)
+			if !ok {
+				continue
+			}
+			var list []string
+			for _, e := range inner.Elts {
+				if s := stringLiteral(e); s != "" {
+					list = append(list, s)
+				}
+			}
+			if len(list) > 0 {
+				lists = append(lists, list)
+			}
+		}
+	}
+	return lists
+}
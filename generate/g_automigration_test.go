@@ -0,0 +1,346 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package generate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeSQLType(t *testing.T) {
+	tests := []struct {
+		sqlType string
+		want    string
+	}{
+		// MySQL display widths are not part of the type, except for the booleans
+		{"int(11)", "int"},
+		{"int", "int"},
+		{"bigint(20) unsigned", "bigint unsigned"},
+		{"tinyint(1)", "tinyint(1)"},
+		{"tinyint(4)", "tinyint"},
+
+		// Synonyms of the drivers
+		{"character varying(255)", "varchar(255)"},
+		{"varchar(255)", "varchar(255)"},
+		{"integer", "int"},
+		{"serial", "int"},
+		{"int8", "bigint"},
+		{"bool", "boolean"},
+		{"timestamp without time zone", "timestamp"},
+		{"timestamptz", "timestamp with time zone"},
+		{"double precision", "double"},
+		{"decimal(10, 2)", "numeric(10,2)"},
+
+		// Case and spaces
+		{"  VARCHAR (64) ", "varchar(64)"},
+		{"Character  Varying(64)", "varchar(64)"},
+	}
+	for _, tt := range tests {
+		if got := normalizeSQLType(tt.sqlType); got != tt.want {
+			t.Errorf("normalizeSQLType(%q) = %q, want %q", tt.sqlType, got, tt.want)
+		}
+	}
+}
+
+func TestTypeWidened(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		want bool
+	}{
+		{"int(11)", "integer", true},
+		{"varchar(64)", "varchar(255)", true},
+		{"varchar(255)", "varchar(64)", false},
+		{"character varying(64)", "varchar(64)", true},
+		{"varchar(255)", "text", true},
+		{"text", "varchar(255)", false},
+		{"text", "longtext", true},
+		{"int", "bigint", true},
+		{"bigint", "int", false},
+		{"tinyint(1)", "smallint", true},
+		{"real", "double precision", true},
+		{"double", "real", false},
+		{"numeric(10,2)", "numeric(12,2)", true},
+		{"numeric(10,2)", "numeric(12,4)", true},
+		{"numeric(10,2)", "numeric(10,4)", false},
+		{"numeric(10,2)", "numeric", true},
+		{"numeric", "numeric(10,2)", false},
+
+		// Types of different families
+		{"int", "varchar(255)", false},
+		{"varchar(255)", "int", false},
+		{"int", "double", false},
+		{"date", "timestamp", false},
+	}
+	for _, tt := range tests {
+		if got := typeWidened(tt.from, tt.to); got != tt.want {
+			t.Errorf("typeWidened(%q, %q) = %t, want %t", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+// change holds what a test compares of a schemaChange
+type change struct {
+	description string
+	note        string
+	review      string
+	downReview  string
+}
+
+func TestDiffSchema(t *testing.T) {
+	column := func(name, sqlType string, null bool) *Column {
+		return &Column{Name: name, SQLType: sqlType, Tag: &OrmTag{Column: name, Null: null}}
+	}
+	table := func(name string, columns ...*Column) *Table {
+		return &Table{
+			Name:    name,
+			Pk:      "id",
+			Fk:      make(map[string]*ForeignKey),
+			Columns: append([]*Column{column("id", "int", false)}, columns...),
+		}
+	}
+	withIndex := func(t *Table, index *Index) *Table {
+		t.Indexes = append(t.Indexes, index)
+		return t
+	}
+	withFk := func(t *Table, fk *ForeignKey) *Table {
+		t.Fk[fk.Name] = fk
+		return t
+	}
+	userFk := &ForeignKey{Name: "user_id", RefTable: "users", RefColumn: "id"}
+
+	tests := []struct {
+		name     string
+		driver   string
+		models   []*Table
+		existing []*Table
+		want     []change
+	}{
+		{
+			name:     "unchanged",
+			driver:   "postgres",
+			models:   []*Table{table("users", column("name", "varchar(255)", false))},
+			existing: []*Table{table("users", column("name", "character varying(255)", false))},
+		},
+		{
+			name:     "display width",
+			driver:   "mysql",
+			models:   []*Table{table("users", column("age", "int", false))},
+			existing: []*Table{table("users", column("age", "int(11)", false))},
+		},
+		{
+			name:   "create tables after the tables they reference",
+			driver: "postgres",
+			models: []*Table{
+				withFk(table("posts", column("user_id", "int", false)), userFk),
+				table("users"),
+			},
+			want: []change{
+				{description: "Create table users"},
+				{description: "Create table posts"},
+			},
+		},
+		{
+			name:   "drop tables before the tables they reference",
+			driver: "postgres",
+			existing: []*Table{
+				table("users"),
+				withFk(table("posts", column("user_id", "int", false)), userFk),
+			},
+			want: []change{
+				{description: "Drop table posts", review: "drops the table and its data, no model maps it"},
+				{description: "Drop table users", review: "drops the table and its data, no model maps it"},
+			},
+		},
+		{
+			name:     "add column",
+			driver:   "postgres",
+			models:   []*Table{table("users", column("email", "varchar(128)", true))},
+			existing: []*Table{table("users")},
+			want:     []change{{description: "Add column users.email"}},
+		},
+		{
+			name:     "add NOT NULL column",
+			driver:   "sqlite",
+			models:   []*Table{table("users", column("email", "varchar(128)", false))},
+			existing: []*Table{table("users")},
+			want: []change{{
+				description: "Add column users.email",
+				note:        "NOT NULL without a default, fails when the table has rows, Down: DROP COLUMN needs SQLite 3.35 or later",
+			}},
+		},
+		{
+			name:     "drop column",
+			driver:   "postgres",
+			models:   []*Table{table("users")},
+			existing: []*Table{table("users", column("email", "varchar(128)", true))},
+			want: []change{{
+				description: "Drop column users.email",
+				review:      "drops the column and its data, no field maps it",
+			}},
+		},
+		{
+			name:     "widen column",
+			driver:   "postgres",
+			models:   []*Table{table("users", column("name", "varchar(255)", false))},
+			existing: []*Table{table("users", column("name", "varchar(64)", false))},
+			want: []change{{
+				description: "Change column users.name from varchar(64) NOT NULL to varchar(255) NOT NULL",
+				downReview:  "the values written since may not convert to the former type",
+			}},
+		},
+		{
+			name:     "narrow column",
+			driver:   "postgres",
+			models:   []*Table{table("users", column("name", "varchar(64)", false))},
+			existing: []*Table{table("users", column("name", "varchar(255)", false))},
+			want: []change{{
+				description: "Change column users.name from varchar(255) NOT NULL to varchar(64) NOT NULL",
+				review:      "existing values may not convert to the new type",
+			}},
+		},
+		{
+			name:     "column becomes NOT NULL",
+			driver:   "postgres",
+			models:   []*Table{table("users", column("name", "varchar(64)", false))},
+			existing: []*Table{table("users", column("name", "varchar(64)", true))},
+			want: []change{{
+				description: "Change column users.name from varchar(64) NULL to varchar(64) NOT NULL",
+				review:      "fails when the column holds NULL values",
+			}},
+		},
+		{
+			name:     "column becomes NULL",
+			driver:   "postgres",
+			models:   []*Table{table("users", column("name", "varchar(64)", true))},
+			existing: []*Table{table("users", column("name", "varchar(64)", false))},
+			want: []change{{
+				description: "Change column users.name from varchar(64) NOT NULL to varchar(64) NULL",
+				downReview:  "the values written since may not convert to the former type",
+			}},
+		},
+		{
+			name:     "alter column unsupported",
+			driver:   "sqlite",
+			models:   []*Table{table("users", column("name", "varchar(255)", false))},
+			existing: []*Table{table("users", column("name", "varchar(64)", false))},
+			want: []change{{
+				description: "Change column users.name from varchar(64) NOT NULL to varchar(255) NOT NULL",
+				review:      "the database cannot alter columns, rebuild the table",
+				downReview:  "the values written since may not convert to the former type",
+			}},
+		},
+		{
+			name:   "create index",
+			driver: "postgres",
+			models: []*Table{withIndex(table("users", column("name", "varchar(64)", false)),
+				&Index{Name: "idx_users_name", Columns: []string{"name"}})},
+			existing: []*Table{table("users", column("name", "varchar(64)", false))},
+			want:     []change{{description: "Create index idx_users_name of users"}},
+		},
+		{
+			name:   "drop index",
+			driver: "postgres",
+			models: []*Table{table("users", column("name", "varchar(64)", false))},
+			existing: []*Table{withIndex(table("users", column("name", "varchar(64)", false)),
+				&Index{Name: "idx_users_name", Columns: []string{"name"}})},
+			want: []change{{description: "Drop index idx_users_name of users"}},
+		},
+		{
+			name:   "renamed index",
+			driver: "postgres",
+			models: []*Table{withIndex(table("users", column("name", "varchar(64)", false)),
+				&Index{Name: "users_name", Columns: []string{"name"}})},
+			existing: []*Table{withIndex(table("users", column("name", "varchar(64)", false)),
+				&Index{Name: "idx_users_name", Columns: []string{"name"}})},
+		},
+		{
+			name:   "drop unique constraint unsupported",
+			driver: "sqlite",
+			models: []*Table{table("users", column("email", "varchar(128)", false))},
+			existing: []*Table{withIndex(table("users", column("email", "varchar(128)", false)),
+				&Index{Name: "sqlite_autoindex_users_1", Columns: []string{"email"}, Unique: true, Constraint: true})},
+			want: []change{{
+				description: "Drop index sqlite_autoindex_users_1 of users",
+				review:      "the database cannot drop the index of a UNIQUE constraint, rebuild the table",
+			}},
+		},
+		{
+			name:     "add foreign key",
+			driver:   "postgres",
+			models:   []*Table{table("users"), withFk(table("posts", column("user_id", "int", false)), userFk)},
+			existing: []*Table{table("users"), table("posts", column("user_id", "int", false))},
+			want:     []change{{description: "Add foreign key posts.user_id referencing users"}},
+		},
+		{
+			name:     "drop foreign key",
+			driver:   "postgres",
+			models:   []*Table{table("users"), table("posts", column("user_id", "int", false))},
+			existing: []*Table{table("users"), withFk(table("posts", column("user_id", "int", false)), userFk)},
+			want:     []change{{description: "Drop foreign key posts.user_id referencing users"}},
+		},
+		{
+			name:   "foreign key pointing elsewhere",
+			driver: "postgres",
+			models: []*Table{
+				table("users"),
+				table("authors"),
+				withFk(table("posts", column("user_id", "int", false)), &ForeignKey{Name: "user_id", RefTable: "authors", RefColumn: "id"}),
+			},
+			existing: []*Table{table("users"), table("authors"), withFk(table("posts", column("user_id", "int", false)), userFk)},
+			want: []change{
+				{description: "Drop foreign key posts.user_id referencing users"},
+				{description: "Add foreign key posts.user_id referencing authors"},
+			},
+		},
+		{
+			name:     "add foreign key to an existing column unsupported",
+			driver:   "sqlite",
+			models:   []*Table{table("users"), withFk(table("posts", column("user_id", "int", false)), userFk)},
+			existing: []*Table{table("users"), table("posts", column("user_id", "int", false))},
+			want: []change{{
+				description: "Add foreign key posts.user_id referencing users",
+				review:      "the database cannot add foreign keys to existing columns, rebuild the table",
+			}},
+		},
+		{
+			name:     "foreign key of a new column on SQLite",
+			driver:   "sqlite",
+			models:   []*Table{table("users"), withFk(table("posts", column("user_id", "int", true)), userFk)},
+			existing: []*Table{table("users"), table("posts")},
+			want: []change{{
+				description: "Add column posts.user_id",
+				note:        "Down: DROP COLUMN needs SQLite 3.35 or later",
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := make(map[string]*Table)
+			var tableNames []string
+			for _, table := range tt.existing {
+				existing[table.Name] = table
+				tableNames = append(tableNames, table.Name)
+			}
+			var got []change
+			for _, c := range diffSchema(newDBDriver(tt.driver), tt.models, existing, tableNames) {
+				got = append(got, change{c.description, c.note, c.review, c.downReview})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffSchema() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
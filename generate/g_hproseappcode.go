@@ -296,7 +296,6 @@ func GenerateHproseAppcode(driver, connStr, level, tables, currpath string) {
 	case "mysql":
 	case "postgres":
 	case "sqlite":
-		iziLogger.Log.Fatal("Generating app code from SQLite database is not supported yet")
 	default:
 		iziLogger.Log.Fatalf("Unknown database driver '%s'. Driver must be one of mysql, postgres or sqlite", driver)
 	}
@@ -306,9 +305,9 @@ func GenerateHproseAppcode(driver, connStr, level, tables, currpath string) {
 // Generate takes table, column and foreign key information from database connection
 // and generate corresponding golang source files
 func genHprose(dbms, connStr string, mode byte, selectedTableNames map[string]bool, currpath string) {
-	db, err := sql.Open(dbms, connStr)
+	db, err := sql.Open(SQLDriverName(dbms), connStr)
 	if err != nil {
-		iziLogger.Log.Fatalf("Could not connect to '%s' database using '%s': %s", dbms, connStr, err)
+		iziLogger.Log.Fatalf("Could not connect to '%s' database using '%s': %s", dbms, utils.MaskDSN(connStr), err)
 	}
 	defer db.Close()
 	if trans, ok := dbDriver[dbms]; ok {
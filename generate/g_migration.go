@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -35,8 +36,30 @@ const (
 type DBDriver interface {
 	GenerateCreateUp(tableName string) string
 	GenerateCreateDown(tableName string) string
+
+	// The statements below are empty when the database cannot run them
+	ColumnType(col *Column) string
+	CreateTable(table *Table) string
+	DropTable(table string) string
+	AddColumn(table string, col *Column, fk *ForeignKey) string
+	DropColumn(table, column string) string
+	RenameColumn(table, column, name string) string
+	AlterColumn(table string, col *Column) []string
+	CreateIndex(table string, index *Index) string
+	DropIndex(table string, index *Index) string
+	AddForeignKey(table string, fk *ForeignKey) string
+	DropForeignKey(table string, fk *ForeignKey) string
+
+	// Requires returns the version of the database the operation needs, if any
+	Requires(op string) string
 }
 
+// Operations which older versions of some databases cannot run
+const (
+	OpDropColumn   = "DROP COLUMN"
+	OpRenameColumn = "RENAME COLUMN"
+)
+
 type mysqlDriver struct{}
 
 func (m mysqlDriver) GenerateCreateUp(tableName string) string {
@@ -106,6 +129,115 @@ func (m mysqlDriver) getSQLType(ktype string) (tp, tag string) {
 	return "", ""
 }
 
+func (m mysqlDriver) quote(name string) string {
+	return "`" + name + "`"
+}
+
+func (m mysqlDriver) ColumnType(col *Column) string {
+	switch {
+	case col.Tag.Type == "text":
+		return "longtext"
+	case col.Tag.Type != "":
+		return col.Tag.Type
+	case col.Tag.Decimals != "":
+		return "decimal(" + col.Tag.Digits + "," + col.Tag.Decimals + ")"
+	}
+	switch col.Type {
+	case "string":
+		return "varchar(" + columnSize(col) + ")"
+	case "bool":
+		return "tinyint(1)"
+	case "int8":
+		return "tinyint"
+	case "int16":
+		return "smallint"
+	case "int", "int32":
+		return "int"
+	case "int64":
+		return "bigint"
+	case "uint8":
+		return "tinyint unsigned"
+	case "uint16":
+		return "smallint unsigned"
+	case "uint", "uint32":
+		return "int unsigned"
+	case "uint64":
+		return "bigint unsigned"
+	case "float32":
+		return "float"
+	case "float64":
+		return "double"
+	case "time.Time":
+		return "datetime"
+	case "[]byte":
+		return "longblob"
+	}
+	return "longtext"
+}
+
+func (m mysqlDriver) CreateTable(table *Table) string {
+	var defs []string
+	for _, col := range table.Columns {
+		switch {
+		case col.Tag.Column == table.Pk && col.Tag.Auto:
+			defs = append(defs, m.quote(col.Tag.Column)+" "+col.SQLType+" NOT NULL AUTO_INCREMENT PRIMARY KEY")
+		case col.Tag.Column == table.Pk:
+			defs = append(defs, m.quote(col.Tag.Column)+" "+col.SQLType+" NOT NULL PRIMARY KEY")
+		default:
+			defs = append(defs, columnDefinition(m.quote, col))
+		}
+	}
+	for _, fk := range sortedForeignKeys(table) {
+		defs = append(defs, fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+			m.quote(foreignKeyName(table.Name, fk)), m.quote(fk.Name), m.quote(fk.RefTable), m.quote(fk.RefColumn)))
+	}
+	return "CREATE TABLE " + m.quote(table.Name) + " (" + strings.Join(defs, ", ") + ")"
+}
+
+func (m mysqlDriver) DropTable(table string) string {
+	return "DROP TABLE " + m.quote(table)
+}
+
+func (m mysqlDriver) AddColumn(table string, col *Column, fk *ForeignKey) string {
+	return "ALTER TABLE " + m.quote(table) + " ADD COLUMN " + columnDefinition(m.quote, col)
+}
+
+func (m mysqlDriver) DropColumn(table, column string) string {
+	return "ALTER TABLE " + m.quote(table) + " DROP COLUMN " + m.quote(column)
+}
+
+func (m mysqlDriver) RenameColumn(table, column, name string) string {
+	return "ALTER TABLE " + m.quote(table) + " RENAME COLUMN " + m.quote(column) + " TO " + m.quote(name)
+}
+
+func (m mysqlDriver) Requires(op string) string {
+	if op == OpRenameColumn {
+		return "MySQL 8.0"
+	}
+	return ""
+}
+
+func (m mysqlDriver) AlterColumn(table string, col *Column) []string {
+	return []string{"ALTER TABLE " + m.quote(table) + " MODIFY COLUMN " + columnDefinition(m.quote, col)}
+}
+
+func (m mysqlDriver) CreateIndex(table string, index *Index) string {
+	return createIndex(m.quote, table, index)
+}
+
+func (m mysqlDriver) DropIndex(table string, index *Index) string {
+	return "DROP INDEX " + m.quote(index.Name) + " ON " + m.quote(table)
+}
+
+func (m mysqlDriver) AddForeignKey(table string, fk *ForeignKey) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+		m.quote(table), m.quote(foreignKeyName(table, fk)), m.quote(fk.Name), m.quote(fk.RefTable), m.quote(fk.RefColumn))
+}
+
+func (m mysqlDriver) DropForeignKey(table string, fk *ForeignKey) string {
+	return "ALTER TABLE " + m.quote(table) + " DROP FOREIGN KEY " + m.quote(foreignKeyName(table, fk))
+}
+
 type postgresqlDriver struct{}
 
 func (m postgresqlDriver) GenerateCreateUp(tableName string) string {
@@ -174,12 +306,351 @@ func (m postgresqlDriver) getSQLType(ktype string) (tp, tag string) {
 	return "", ""
 }
 
+func (m postgresqlDriver) quote(name string) string {
+	return name
+}
+
+func (m postgresqlDriver) ColumnType(col *Column) string {
+	switch {
+	case col.Tag.Type != "":
+		return col.Tag.Type
+	case col.Tag.Decimals != "":
+		return "numeric(" + col.Tag.Digits + "," + col.Tag.Decimals + ")"
+	}
+	switch col.Type {
+	case "string":
+		return "varchar(" + columnSize(col) + ")"
+	case "bool":
+		return "boolean"
+	case "int8", "int16", "uint8":
+		return "smallint"
+	case "int", "int32", "uint16":
+		return "integer"
+	case "int64", "uint", "uint32", "uint64":
+		return "bigint"
+	case "float32":
+		return "real"
+	case "float64":
+		return "double precision"
+	case "time.Time":
+		return "timestamp"
+	case "[]byte":
+		return "bytea"
+	}
+	return "text"
+}
+
+func (m postgresqlDriver) CreateTable(table *Table) string {
+	var defs []string
+	for _, col := range table.Columns {
+		switch {
+		case col.Tag.Column == table.Pk && col.Tag.Auto && normalizeSQLType(col.SQLType) == "bigint":
+			defs = append(defs, col.Tag.Column+" bigserial PRIMARY KEY")
+		case col.Tag.Column == table.Pk && col.Tag.Auto:
+			defs = append(defs, col.Tag.Column+" serial PRIMARY KEY")
+		case col.Tag.Column == table.Pk:
+			defs = append(defs, col.Tag.Column+" "+col.SQLType+" NOT NULL PRIMARY KEY")
+		default:
+			defs = append(defs, columnDefinition(m.quote, col))
+		}
+	}
+	for _, fk := range sortedForeignKeys(table) {
+		defs = append(defs, fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+			foreignKeyName(table.Name, fk), fk.Name, fk.RefTable, fk.RefColumn))
+	}
+	return "CREATE TABLE " + table.Name + " (" + strings.Join(defs, ", ") + ")"
+}
+
+func (m postgresqlDriver) DropTable(table string) string {
+	return "DROP TABLE " + table
+}
+
+func (m postgresqlDriver) AddColumn(table string, col *Column, fk *ForeignKey) string {
+	return "ALTER TABLE " + table + " ADD COLUMN " + columnDefinition(m.quote, col)
+}
+
+func (m postgresqlDriver) DropColumn(table, column string) string {
+	return "ALTER TABLE " + table + " DROP COLUMN " + column
+}
+
+func (m postgresqlDriver) RenameColumn(table, column, name string) string {
+	return "ALTER TABLE " + table + " RENAME COLUMN " + column + " TO " + name
+}
+
+func (m postgresqlDriver) Requires(op string) string {
+	return ""
+}
+
+func (m postgresqlDriver) AlterColumn(table string, col *Column) []string {
+	name := col.Tag.Column
+	statements := []string{fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s", table, name, col.SQLType, name, col.SQLType)}
+	if col.Tag.Null {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL", table, name))
+	} else {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL", table, name))
+	}
+	return statements
+}
+
+func (m postgresqlDriver) CreateIndex(table string, index *Index) string {
+	return createIndex(m.quote, table, index)
+}
+
+func (m postgresqlDriver) DropIndex(table string, index *Index) string {
+	if index.Constraint {
+		return "ALTER TABLE " + table + " DROP CONSTRAINT " + index.Name
+	}
+	return "DROP INDEX " + index.Name
+}
+
+func (m postgresqlDriver) AddForeignKey(table string, fk *ForeignKey) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+		table, foreignKeyName(table, fk), fk.Name, fk.RefTable, fk.RefColumn)
+}
+
+func (m postgresqlDriver) DropForeignKey(table string, fk *ForeignKey) string {
+	return "ALTER TABLE " + table + " DROP CONSTRAINT " + foreignKeyName(table, fk)
+}
+
+type sqliteDriver struct{}
+
+func (m sqliteDriver) GenerateCreateUp(tableName string) string {
+	upsql := `m.SQL("CREATE TABLE ` + tableName + "(" + m.generateSQLFromFields(Fields.String()) + `)");`
+	return upsql
+}
+
+func (m sqliteDriver) GenerateCreateDown(tableName string) string {
+	downsql := `m.SQL("DROP TABLE ` + tableName + `")`
+	return downsql
+}
+
+func (m sqliteDriver) generateSQLFromFields(fields string) string {
+	sql := ""
+	fds := strings.Split(fields, ",")
+	for i, v := range fds {
+		kv := strings.SplitN(v, ":", 2)
+		if len(kv) != 2 {
+			iziLogger.Log.Error("Fields format is wrong. Should be: key:type,key:type " + v)
+			return ""
+		}
+		typ := m.getSQLType(kv[1])
+		if typ == "" {
+			iziLogger.Log.Error("Fields format is wrong. Should be: key:type,key:type " + v)
+			return ""
+		}
+		if i == 0 && strings.ToLower(kv[0]) != "id" {
+			sql += "id integer PRIMARY KEY AUTOINCREMENT,"
+		}
+		sql += utils.SnakeString(kv[0]) + " " + typ + ","
+	}
+	return strings.TrimRight(sql, ",")
+}
+
+func (m sqliteDriver) getSQLType(ktype string) string {
+	kv := strings.SplitN(ktype, ":", 2)
+	switch kv[0] {
+	case "string":
+		if len(kv) == 2 {
+			return "varchar(" + kv[1] + ") NOT NULL"
+		}
+		return "varchar(128) NOT NULL"
+	case "text":
+		return "text NOT NULL"
+	case "auto", "pk":
+		return "integer PRIMARY KEY AUTOINCREMENT"
+	case "datetime":
+		return "datetime NOT NULL"
+	case "int", "int8", "int16", "int32", "int64":
+		fallthrough
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		return "integer DEFAULT NULL"
+	case "bool":
+		return "boolean NOT NULL"
+	case "float32", "float64", "float":
+		return "real NOT NULL"
+	}
+	return ""
+}
+
+func (m sqliteDriver) quote(name string) string {
+	return name
+}
+
+func (m sqliteDriver) ColumnType(col *Column) string {
+	switch {
+	case col.Tag.Type != "":
+		return col.Tag.Type
+	case col.Tag.Decimals != "":
+		return "decimal(" + col.Tag.Digits + "," + col.Tag.Decimals + ")"
+	}
+	switch col.Type {
+	case "string":
+		return "varchar(" + columnSize(col) + ")"
+	case "bool":
+		return "boolean"
+	case "int8", "uint8":
+		return "tinyint"
+	case "int16", "uint16":
+		return "smallint"
+	case "int", "int32", "uint", "uint32":
+		return "integer"
+	case "int64", "uint64":
+		return "bigint"
+	case "float32", "float64":
+		return "real"
+	case "time.Time":
+		return "datetime"
+	case "[]byte":
+		return "blob"
+	}
+	return "text"
+}
+
+func (m sqliteDriver) CreateTable(table *Table) string {
+	var defs []string
+	for _, col := range table.Columns {
+		switch {
+		case col.Tag.Column == table.Pk && col.Tag.Auto:
+			defs = append(defs, col.Tag.Column+" integer PRIMARY KEY AUTOINCREMENT")
+		case col.Tag.Column == table.Pk:
+			defs = append(defs, col.Tag.Column+" "+col.SQLType+" NOT NULL PRIMARY KEY")
+		default:
+			defs = append(defs, columnDefinition(m.quote, col))
+		}
+	}
+	for _, fk := range sortedForeignKeys(table) {
+		defs = append(defs, fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)", fk.Name, fk.RefTable, fk.RefColumn))
+	}
+	return "CREATE TABLE " + table.Name + " (" + strings.Join(defs, ", ") + ")"
+}
+
+func (m sqliteDriver) DropTable(table string) string {
+	return "DROP TABLE " + table
+}
+
+// AddColumn declares the foreign key with the column, SQLite cannot add it later
+func (m sqliteDriver) AddColumn(table string, col *Column, fk *ForeignKey) string {
+	statement := "ALTER TABLE " + table + " ADD COLUMN " + columnDefinition(m.quote, col)
+	if fk != nil {
+		statement += " REFERENCES " + fk.RefTable + " (" + fk.RefColumn + ")"
+	}
+	return statement
+}
+
+func (m sqliteDriver) DropColumn(table, column string) string {
+	return "ALTER TABLE " + table + " DROP COLUMN " + column
+}
+
+func (m sqliteDriver) RenameColumn(table, column, name string) string {
+	return "ALTER TABLE " + table + " RENAME COLUMN " + column + " TO " + name
+}
+
+func (m sqliteDriver) Requires(op string) string {
+	switch op {
+	case OpDropColumn:
+		return "SQLite 3.35"
+	case OpRenameColumn:
+		return "SQLite 3.25"
+	}
+	return ""
+}
+
+func (m sqliteDriver) AlterColumn(table string, col *Column) []string {
+	return nil
+}
+
+func (m sqliteDriver) CreateIndex(table string, index *Index) string {
+	return createIndex(m.quote, table, index)
+}
+
+// DropIndex cannot drop the indexes of UNIQUE column constraints
+func (m sqliteDriver) DropIndex(table string, index *Index) string {
+	if index.Constraint {
+		return ""
+	}
+	return "DROP INDEX " + index.Name
+}
+
+func (m sqliteDriver) AddForeignKey(table string, fk *ForeignKey) string {
+	return ""
+}
+
+func (m sqliteDriver) DropForeignKey(table string, fk *ForeignKey) string {
+	return ""
+}
+
+// columnDefinition returns the definition of a column other than the primary key
+func columnDefinition(quote func(string) string, col *Column) string {
+	def := quote(col.Tag.Column) + " " + col.SQLType
+	if !col.Tag.Null {
+		def += " NOT NULL"
+	}
+	if col.Tag.Default != "" {
+		def += " DEFAULT " + sqlLiteral(col.Tag.Default)
+	}
+	return def
+}
+
+// sqlLiteral quotes a default value unless it is a number or a boolean
+func sqlLiteral(value string) string {
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+	if lower := strings.ToLower(value); lower == "true" || lower == "false" || lower == "null" {
+		return value
+	}
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+func columnSize(col *Column) string {
+	if col.Tag.Size != "" {
+		return col.Tag.Size
+	}
+	return "255"
+}
+
+func createIndex(quote func(string) string, table string, index *Index) string {
+	columns := make([]string, len(index.Columns))
+	for i, c := range index.Columns {
+		columns[i] = quote(c)
+	}
+	create := "CREATE INDEX "
+	if index.Unique {
+		create = "CREATE UNIQUE INDEX "
+	}
+	return create + quote(index.Name) + " ON " + quote(table) + " (" + strings.Join(columns, ", ") + ")"
+}
+
+// foreignKeyName returns the name of the constraint, fk_<table>_<column> for the new ones
+func foreignKeyName(table string, fk *ForeignKey) string {
+	if fk.Constraint != "" {
+		return fk.Constraint
+	}
+	return "fk_" + table + "_" + fk.Name
+}
+
+func sortedForeignKeys(table *Table) []*ForeignKey {
+	var fks []*ForeignKey
+	for _, col := range table.Columns {
+		if fk, ok := table.Fk[col.Tag.Column]; ok {
+			fks = append(fks, fk)
+		}
+	}
+	return fks
+}
+
 func NewDBDriver() DBDriver {
-	switch SQLDriver {
+	return newDBDriver(SQLDriver.String())
+}
+
+func newDBDriver(driver string) DBDriver {
+	switch driver {
 	case "mysql":
 		return mysqlDriver{}
 	case "postgres":
 		return postgresqlDriver{}
+	case "sqlite":
+		return sqliteDriver{}
 	default:
 		iziLogger.Log.Fatal("Driver not supported")
 		return nil
@@ -21,6 +21,7 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
@@ -86,12 +87,19 @@ var stdlibObject = map[string]string{
 }
 
 func init() {
+	ResetCache()
+}
+
+// ResetCache forgets the parsed packages and the generated definitions,
+// so that the docs can be generated again by the same process.
+func ResetCache() {
 	pkgCache = make(map[string]struct{})
 	controllerComments = make(map[string]string)
 	importlist = make(map[string]string)
 	controllerList = make(map[string]map[string]*swagger.Item)
 	modelsList = make(map[string]map[string]swagger.Schema)
 	astPkgs = make([]*ast.Package, 0)
+	rootapi = swagger.Swagger{}
 }
 
 // ParsePackagesFromDir parses packages from a given directory
@@ -147,13 +155,14 @@ func parsePackageFromDir(path string) error {
 	return nil
 }
 
-// GenerateDocs generates documentations for a given path.
-func GenerateDocs(curpath string) {
+// GenerateDocs generates documentations for a given path. The errors of
+// the annotations are returned.
+func GenerateDocs(curpath string) error {
 	fset := token.NewFileSet()
 
 	f, err := parser.ParseFile(fset, filepath.Join(curpath, "routers", "router.go"), nil, parser.ParseComments)
 	if err != nil {
-		iziLogger.Log.Fatalf("Error while parsing router.go: %s", err)
+		return fmt.Errorf("Error while parsing router.go: %s", err)
 	}
 
 	rootapi.Infos = swagger.Information{}
@@ -200,16 +209,16 @@ func GenerateDocs(curpath string) {
 					var out swagger.Security
 					p := getparams(strings.TrimSpace(s[len("@SecurityDefinition"):]))
 					if len(p) < 2 {
-						iziLogger.Log.Fatalf("Not enough params for security: %d\n", len(p))
+						return fmt.Errorf("Not enough params for security: %d", len(p))
 					}
 					out.Type = p[1]
 					switch out.Type {
 					case "oauth2":
 						if len(p) < 6 {
-							iziLogger.Log.Fatalf("Not enough params for oauth2: %d\n", len(p))
+							return fmt.Errorf("Not enough params for oauth2: %d", len(p))
 						}
 						if !(p[3] == "implicit" || p[3] == "password" || p[3] == "application" || p[3] == "accessCode") {
-							iziLogger.Log.Fatalf("Unknown flow type: %s. Possible values are `implicit`, `password`, `application` or `accessCode`.\n", p[1])
+							return fmt.Errorf("Unknown flow type: %s. Possible values are `implicit`, `password`, `application` or `accessCode`.", p[3])
 						}
 						out.AuthorizationURL = p[2]
 						out.Flow = p[3]
@@ -222,10 +231,10 @@ func GenerateDocs(curpath string) {
 						}
 					case "apiKey":
 						if len(p) < 4 {
-							iziLogger.Log.Fatalf("Not enough params for apiKey: %d\n", len(p))
+							return fmt.Errorf("Not enough params for apiKey: %d", len(p))
 						}
 						if !(p[3] == "header" || p[3] == "query") {
-							iziLogger.Log.Fatalf("Unknown in type: %s. Possible values are `query` or `header`.\n", p[4])
+							return fmt.Errorf("Unknown in type: %s. Possible values are `query` or `header`.", p[3])
 						}
 						out.Name = p[2]
 						out.In = p[3]
@@ -237,14 +246,18 @@ func GenerateDocs(curpath string) {
 							out.Description = strings.Trim(p[2], `" `)
 						}
 					default:
-						iziLogger.Log.Fatalf("Unknown security type: %s. Possible values are `oauth2`, `apiKey` or `basic`.\n", p[1])
+						return fmt.Errorf("Unknown security type: %s. Possible values are `oauth2`, `apiKey` or `basic`.", p[1])
 					}
 					rootapi.SecurityDefinitions[p[0]] = out
 				} else if strings.HasPrefix(s, "@Security") {
 					if len(rootapi.Security) == 0 {
 						rootapi.Security = make([]map[string][]string, 0)
 					}
-					rootapi.Security = append(rootapi.Security, getSecurity(s))
+					security, err := getSecurity(s)
+					if err != nil {
+						return err
+					}
+					rootapi.Security = append(rootapi.Security, security)
 				}
 			}
 		}
@@ -255,7 +268,9 @@ func GenerateDocs(curpath string) {
 		if im.Name != nil {
 			localName = im.Name.Name
 		}
-		analyseControllerPkg(path.Join(curpath, "vendor"), localName, im.Path.Value)
+		if err := analyseControllerPkg(path.Join(curpath, "vendor"), localName, im.Path.Value); err != nil {
+			return err
+		}
 	}
 	for _, d := range f.Decls {
 		switch specDecl := d.(type) {
@@ -313,26 +328,18 @@ func GenerateDocs(curpath string) {
 		}
 	}
 	os.Mkdir(path.Join(curpath, "swagger"), 0755)
-	fd, err := os.Create(path.Join(curpath, "swagger", "swagger.json"))
+	dt, err := json.MarshalIndent(rootapi, "", "    ")
 	if err != nil {
-		panic(err)
+		return err
 	}
-	fdyml, err := os.Create(path.Join(curpath, "swagger", "swagger.yml"))
+	dtyml, err := yaml.Marshal(rootapi)
 	if err != nil {
-		panic(err)
-	}
-	defer fdyml.Close()
-	defer fd.Close()
-	dt, err := json.MarshalIndent(rootapi, "", "    ")
-	dtyml, erryml := yaml.Marshal(rootapi)
-	if err != nil || erryml != nil {
-		panic(err)
+		return err
 	}
-	_, err = fd.Write(dt)
-	_, erryml = fdyml.Write(dtyml)
-	if err != nil || erryml != nil {
-		panic(err)
+	if err := ioutil.WriteFile(path.Join(curpath, "swagger", "swagger.json"), dt, 0644); err != nil {
+		return err
 	}
+	return ioutil.WriteFile(path.Join(curpath, "swagger", "swagger.yml"), dtyml, 0644)
 }
 
 // analyseNewNamespace returns version and the others params
@@ -410,13 +417,13 @@ func analyseNSInclude(baseurl string, ce *ast.CallExpr) string {
 	return cname
 }
 
-func analyseControllerPkg(vendorPath, localName, pkgpath string) {
+func analyseControllerPkg(vendorPath, localName, pkgpath string) error {
 	pkgpath = strings.Trim(pkgpath, "\"")
-	if isSystemPackage(pkgpath) {
-		return
+	if system, err := isSystemPackage(pkgpath); system || err != nil {
+		return err
 	}
 	if pkgpath == "github.com/izi-global/izigo" {
-		return
+		return nil
 	}
 	if localName != "" {
 		importlist[localName] = pkgpath
@@ -426,7 +433,7 @@ func analyseControllerPkg(vendorPath, localName, pkgpath string) {
 	}
 	gopaths := bu.GetGOPATHs()
 	if len(gopaths) == 0 {
-		iziLogger.Log.Fatal("GOPATH environment variable is not set or empty")
+		return errors.New("GOPATH environment variable is not set or empty")
 	}
 	pkgRealpath := ""
 
@@ -445,11 +452,11 @@ func analyseControllerPkg(vendorPath, localName, pkgpath string) {
 	}
 	if pkgRealpath != "" {
 		if _, ok := pkgCache[pkgpath]; ok {
-			return
+			return nil
 		}
 		pkgCache[pkgpath] = struct{}{}
 	} else {
-		iziLogger.Log.Fatalf("Package '%s' does not exist in the GOPATH or vendor path", pkgpath)
+		return fmt.Errorf("Package '%s' does not exist in the GOPATH or vendor path", pkgpath)
 	}
 
 	fileSet := token.NewFileSet()
@@ -458,7 +465,7 @@ func analyseControllerPkg(vendorPath, localName, pkgpath string) {
 		return !info.IsDir() && !strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".go")
 	}, parser.ParseComments)
 	if err != nil {
-		iziLogger.Log.Fatalf("Error while parsing dir at '%s': %s", pkgpath, err)
+		return fmt.Errorf("Error while parsing dir at '%s': %s", pkgpath, err)
 	}
 	for _, pkg := range astPkgs {
 		for _, fl := range pkg.Files {
@@ -468,7 +475,9 @@ func analyseControllerPkg(vendorPath, localName, pkgpath string) {
 					if specDecl.Recv != nil && len(specDecl.Recv.List) > 0 {
 						if t, ok := specDecl.Recv.List[0].Type.(*ast.StarExpr); ok {
 							// Parse controller method
-							parserComments(specDecl, fmt.Sprint(t.X), pkgpath)
+							if err := parserComments(specDecl, fmt.Sprint(t.X), pkgpath); err != nil {
+								return err
+							}
 						}
 					}
 				case *ast.GenDecl:
@@ -488,25 +497,26 @@ func analyseControllerPkg(vendorPath, localName, pkgpath string) {
 			}
 		}
 	}
+	return nil
 }
 
-func isSystemPackage(pkgpath string) bool {
+func isSystemPackage(pkgpath string) (bool, error) {
 	goroot := os.Getenv("GOROOT")
 	if goroot == "" {
 		goroot = runtime.GOROOT()
 	}
 	if goroot == "" {
-		iziLogger.Log.Fatalf("GOROOT environment variable is not set or empty")
+		return false, errors.New("GOROOT environment variable is not set or empty")
 	}
 
 	wg, _ := filepath.EvalSymlinks(filepath.Join(goroot, "src", "pkg", pkgpath))
 	if utils.FileExists(wg) {
-		return true
+		return true, nil
 	}
 
 	//TODO(zh):support go1.4
 	wg, _ = filepath.EvalSymlinks(filepath.Join(goroot, "src", pkgpath))
-	return utils.FileExists(wg)
+	return utils.FileExists(wg), nil
 }
 
 func peekNextSplitString(ss string) (s string, spacePos int) {
@@ -564,7 +574,7 @@ func parserComments(f *ast.FuncDecl, controllerName, pkgpath string) error {
 					ss = strings.TrimSpace(ss[pos:])
 					schemaName, pos := peekNextSplitString(ss)
 					if schemaName == "" {
-						iziLogger.Log.Fatalf("[%s.%s] Schema must follow {object} or {array}", controllerName, funcName)
+						return fmt.Errorf("[%s.%s] Schema must follow {object} or {array}", controllerName, funcName)
 					}
 					if strings.HasPrefix(schemaName, "[]") {
 						schemaName = schemaName[2:]
@@ -601,7 +611,7 @@ func parserComments(f *ast.FuncDecl, controllerName, pkgpath string) error {
 				para := swagger.Parameter{}
 				p := getparams(strings.TrimSpace(t[len("@Param "):]))
 				if len(p) < 4 {
-					iziLogger.Log.Fatal(controllerName + "_" + funcName + "'s comments @Param should have at least 4 params")
+					return errors.New(controllerName + "_" + funcName + "'s comments @Param should have at least 4 params")
 				}
 				paramNames := strings.SplitN(p[0], "=>", 2)
 				para.Name = paramNames[0]
@@ -718,7 +728,11 @@ func parserComments(f *ast.FuncDecl, controllerName, pkgpath string) error {
 				if len(opts.Security) == 0 {
 					opts.Security = make([]map[string][]string, 0)
 				}
-				opts.Security = append(opts.Security, getSecurity(t))
+				security, err := getSecurity(t)
+				if err != nil {
+					return err
+				}
+				opts.Security = append(opts.Security, security)
 			}
 		}
 	}
@@ -937,7 +951,8 @@ func getModel(str string) (objectname string, m swagger.Schema, realTypes []stri
 func parseObject(d *ast.Object, k string, m *swagger.Schema, realTypes *[]string, astPkgs []*ast.Package, packageName string) {
 	ts, ok := d.Decl.(*ast.TypeSpec)
 	if !ok {
-		iziLogger.Log.Fatalf("Unknown type without TypeSec: %v\n", d)
+		iziLogger.Log.Warnf("Unknown type without TypeSec: %v", d)
+		return
 	}
 	// TODO support other types, such as `ArrayType`, `MapType`, `InterfaceType` etc...
 	switch t := ts.Type.(type) {
@@ -983,7 +998,8 @@ func parseIdent(st *ast.Ident, k string, m *swagger.Schema, astPkgs []*ast.Packa
 				if obj.Kind == ast.Con {
 					vs, ok := obj.Decl.(*ast.ValueSpec)
 					if !ok {
-						iziLogger.Log.Fatalf("Unknown type without ValueSpec: %v\n", vs)
+						iziLogger.Log.Warnf("Unknown type without ValueSpec: %v", obj)
+						continue
 					}
 
 					ti, ok := vs.Type.(*ast.Ident)
@@ -1258,11 +1274,11 @@ func appendModels(pkgpath, controllerName string, realTypes []string) {
 	}
 }
 
-func getSecurity(t string) (security map[string][]string) {
+func getSecurity(t string) (security map[string][]string, err error) {
 	security = make(map[string][]string)
 	p := getparams(strings.TrimSpace(t[len("@Security"):]))
 	if len(p) == 0 {
-		iziLogger.Log.Fatalf("No params for security specified\n")
+		return nil, errors.New("No params for security specified")
 	}
 	security[p[0]] = make([]string, 0)
 	for i := 1; i < len(p); i++ {
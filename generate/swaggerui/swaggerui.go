@@ -0,0 +1,94 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package swaggerui bundles a pinned Swagger UI distribution in the izi
+// binary, so that the API documentation can be served without network access.
+//
+// The dist directory holds the files of the swagger-ui-dist package of the
+// version below. They are committed, "make swagger-ui" replaces them when
+// the version is bumped.
+package swaggerui
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/izi-global/izi/utils"
+)
+
+// Version of the bundled swagger-ui-dist package
+const Version = "5.18.2"
+
+//go:embed dist
+var dist embed.FS
+
+// Files needed for a working Swagger UI
+var requiredFiles = []string{
+	"index.html",
+	"swagger-ui.css",
+	"swagger-ui-bundle.js",
+	"swagger-ui-standalone-preset.js",
+}
+
+// ErrIncomplete is returned when izi was built without the Swagger UI assets.
+var ErrIncomplete = errors.New("this izi binary was built without the Swagger UI assets, restore generate/swaggerui/dist before building it")
+
+// FS returns the bundled Swagger UI files.
+func FS() (fs.FS, error) {
+	sub, err := fs.Sub(dist, "dist")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range requiredFiles {
+		if _, err := fs.Stat(sub, name); err != nil {
+			return nil, ErrIncomplete
+		}
+	}
+	return sub, nil
+}
+
+// Install extracts the bundled Swagger UI into dir. Existing files are
+// overwritten, other files such as swagger.json are left untouched.
+func Install(dir string) error {
+	ui, err := FS()
+	if err != nil {
+		return err
+	}
+
+	return fs.WalkDir(ui, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target, err := utils.SecureJoin(dir, name)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		data, err := fs.ReadFile(ui, name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, 0644)
+	})
+}
@@ -0,0 +1,160 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	dotenvKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+	dotenvVarRegex = regexp.MustCompile(`^\$(\{[A-Za-z_][A-Za-z0-9_]*\}|[A-Za-z_][A-Za-z0-9_]*)`)
+)
+
+// ParseDotenvFile reads a dotenv file and returns its variables as
+// KEY=value pairs, in the order they are defined. Variables referenced
+// with $VAR or ${VAR} are looked up in the variables already defined in
+// the file first, then in env and finally in the process environment.
+func ParseDotenvFile(filename string, env map[string]string) ([]string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	pairs, err := ParseDotenv(string(data), env)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return pairs, nil
+}
+
+// ParseDotenv parses the content of a dotenv file. It supports comments,
+// "export" prefixes, single quoted (literal) values, double quoted values
+// with escape sequences spanning several lines and variable expansion in
+// unquoted and double quoted values.
+func ParseDotenv(content string, env map[string]string) ([]string, error) {
+	vars := make(map[string]string)
+	lookup := func(key string) string {
+		if v, ok := vars[key]; ok {
+			return v
+		}
+		if v, ok := env[key]; ok {
+			return v
+		}
+		return os.Getenv(key)
+	}
+
+	var pairs []string
+	lines := strings.Split(strings.Replace(content, "\r\n", "\n", -1), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: missing '='", lineNo)
+		}
+		key := strings.TrimSpace(line[:eq])
+		if !dotenvKeyRegex.MatchString(key) {
+			return nil, fmt.Errorf("line %d: invalid variable name '%s'", lineNo, key)
+		}
+		value := strings.TrimSpace(line[eq+1:])
+
+		switch {
+		case strings.HasPrefix(value, "'"):
+			// Single quoted values are taken literally
+			end := strings.Index(value[1:], "'")
+			for end < 0 && i+1 < len(lines) {
+				i++
+				value += "\n" + lines[i]
+				end = strings.Index(value[1:], "'")
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quoted value", lineNo)
+			}
+			value = value[1 : end+1]
+		case strings.HasPrefix(value, `"`):
+			end := closingQuote(value)
+			for end < 0 && i+1 < len(lines) {
+				i++
+				value += "\n" + lines[i]
+				end = closingQuote(value)
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quoted value", lineNo)
+			}
+			value = expandDotenv(value[1:end], true, lookup)
+		default:
+			if idx := strings.Index(value, " #"); idx >= 0 {
+				value = strings.TrimSpace(value[:idx])
+			}
+			value = expandDotenv(value, false, lookup)
+		}
+
+		vars[key] = value
+		pairs = append(pairs, key+"="+value)
+	}
+	return pairs, nil
+}
+
+// closingQuote returns the index of the unescaped double quote
+// closing the value, or -1 if there is none.
+func closingQuote(value string) int {
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// Escape sequences of double quoted values
+var dotenvEscapes = map[byte]string{'n': "\n", 'r': "\r", 't': "\t", '"': `"`, '\\': `\`, '$': "$"}
+
+// expandDotenv replaces the variables of the value, \$ escaping the dollar.
+// With escapes, the escape sequences of double quoted values are replaced
+// in the same pass, so \\$VAR is a backslash followed by the variable.
+func expandDotenv(value string, escapes bool, lookup func(string) string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\' && i+1 < len(value) && (value[i+1] == '$' || escapes && dotenvEscapes[value[i+1]] != ""):
+			i++
+			b.WriteString(dotenvEscapes[value[i]])
+		case c == '$':
+			m := dotenvVarRegex.FindStringSubmatch(value[i:])
+			if m == nil {
+				b.WriteByte(c)
+				continue
+			}
+			i += len(m[0]) - 1
+			b.WriteString(lookup(strings.Trim(m[1], "{}")))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
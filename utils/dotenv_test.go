@@ -0,0 +1,132 @@
+// Copyright 2018 IZI Global
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	t.Setenv("IZI_DOTENV_PROCESS", "process")
+	env := map[string]string{"FROM_ENV": "env", "SHADOWED": "env"}
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "plain values",
+			content: "A=1\nB = two \n",
+			want:    []string{"A=1", "B=two"},
+		},
+		{
+			name:    "comments and blank lines",
+			content: "# comment\n\n  # indented comment\nA=1 # trailing comment\nB=a#b\n",
+			want:    []string{"A=1", "B=a#b"},
+		},
+		{
+			name:    "export prefix",
+			content: "export A=1\n",
+			want:    []string{"A=1"},
+		},
+		{
+			name:    "empty value",
+			content: "A=\nB=''\nC=\"\"",
+			want:    []string{"A=", "B=", "C="},
+		},
+		{
+			name:    "CRLF line endings",
+			content: "A=1\r\nB=2\r\n",
+			want:    []string{"A=1", "B=2"},
+		},
+		{
+			name:    "single quotes are literal",
+			content: `A='$FROM_ENV \n # not a comment'`,
+			want:    []string{`A=$FROM_ENV \n # not a comment`},
+		},
+		{
+			name:    "multiline single quotes",
+			content: "A='first\nsecond'\nB=1",
+			want:    []string{"A=first\nsecond", "B=1"},
+		},
+		{
+			name:    "double quote escapes",
+			content: `A="tab\tnew\nline \"quoted\" back\\slash \x"`,
+			want:    []string{"A=tab\tnew\nline \"quoted\" back\\slash \\x"},
+		},
+		{
+			name:    "multiline double quotes",
+			content: "A=\"first\nsecond $FROM_ENV\"\nB=1",
+			want:    []string{"A=first\nsecond env", "B=1"},
+		},
+		{
+			name:    "expansion",
+			content: "A=$FROM_ENV\nB=${FROM_ENV}-x\nC=\"$IZI_DOTENV_PROCESS\"\nD=$UNDEFINED_IZI_VAR.\n",
+			want:    []string{"A=env", "B=env-x", "C=process", "D=."},
+		},
+		{
+			name:    "variables of the file first",
+			content: "SHADOWED=file\nA=$SHADOWED\nB=${A}/b",
+			want:    []string{"SHADOWED=file", "A=file", "B=file/b"},
+		},
+		{
+			name:    "dollars which are not variables",
+			content: "A=cost $5\nB=\"100$\"\nC=${}",
+			want:    []string{"A=cost $5", "B=100$", "C=${}"},
+		},
+		{
+			name:    "escaped dollar",
+			content: `A=\$FROM_ENV` + "\n" + `B="\$FROM_ENV and \${FROM_ENV}"`,
+			want:    []string{"A=$FROM_ENV", "B=$FROM_ENV and ${FROM_ENV}"},
+		},
+		{
+			name:    "escaped backslash before a variable",
+			content: `A="\\$FROM_ENV"` + "\n" + `B=\\$FROM_ENV`,
+			want:    []string{`A=\env`, `B=\$FROM_ENV`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDotenv(tt.content, env)
+			if err != nil {
+				t.Fatalf("ParseDotenv(%q) error: %s", tt.content, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDotenv(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"A=1\nB", "line 2: missing '='"},
+		{"1A=1", "line 1: invalid variable name '1A'"},
+		{"A B=1", "line 1: invalid variable name 'A B'"},
+		{"A=1\nB='open\nC=2", "line 2: unterminated quoted value"},
+		{`A="open \"`, "line 1: unterminated quoted value"},
+	}
+	for _, tt := range tests {
+		_, err := ParseDotenv(tt.content, nil)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("ParseDotenv(%q) error = %v, want %s", tt.content, err, tt.want)
+		}
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
@@ -407,6 +408,22 @@ func SplitQuotedFields(in string) []string {
 	return r
 }
 
+// SecureJoin joins an untrusted relative path, e.g. a name read from an
+// archive, to the base directory. It returns an error if the result
+// would escape the base directory.
+func SecureJoin(base, name string) (string, error) {
+	name = filepath.FromSlash(name)
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("illegal absolute path '%s'", name)
+	}
+	joined := filepath.Join(base, name)
+	rel, err := filepath.Rel(base, joined)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("illegal path '%s' escapes '%s'", name, base)
+	}
+	return joined, nil
+}
+
 // GetFileModTime returns unix timestamp of `os.File.ModTime` for the given path.
 func GetFileModTime(path string) int64 {
 	path = strings.Replace(path, "\\", "/", -1)
@@ -426,6 +443,39 @@ func GetFileModTime(path string) int64 {
 	return fi.ModTime().Unix()
 }
 
+// SortByDependencies orders the items so every item comes after the ones it
+// depends on, e.g. tables after the tables their foreign keys reference.
+// The items of a cycle keep their order.
+func SortByDependencies(items []string, deps map[string][]string) []string {
+	var (
+		sorted  []string
+		visited = make(map[string]bool)
+		visit   func(string)
+	)
+	known := make(map[string]bool)
+	for _, item := range items {
+		known[item] = true
+	}
+	visit = func(item string) {
+		if visited[item] {
+			return
+		}
+		visited[item] = true
+		dependencies := append([]string(nil), deps[item]...)
+		sort.Strings(dependencies)
+		for _, dep := range dependencies {
+			if known[dep] {
+				visit(dep)
+			}
+		}
+		sorted = append(sorted, item)
+	}
+	for _, item := range items {
+		visit(item)
+	}
+	return sorted
+}
+
 func defaultGOPATH() string {
 	env := "HOME"
 	if runtime.GOOS == "windows" {